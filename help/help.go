@@ -10,9 +10,11 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/hertz-contrib/binding/go_playground"
 	"github.com/hertz-contrib/requestid"
+	"github.com/weplanx/go/passlib"
 	"os"
 	"reflect"
 	"regexp"
+	"strings"
 )
 
 func Ptr[T any](i T) *T {
@@ -65,6 +67,20 @@ func Validator() *go_playground.Validator {
 		}
 		return matched
 	})
+	// Params name sibling fields that must not appear in the password, e.g. password=Name Email
+	vdx.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		if fl.Field().Kind() != reflect.String {
+			return false
+		}
+		var terms []string
+		parent := reflect.Indirect(fl.Parent())
+		for _, name := range strings.Fields(fl.Param()) {
+			if v := parent.FieldByName(name); v.IsValid() && v.Kind() == reflect.String {
+				terms = append(terms, v.String())
+			}
+		}
+		return passlib.DefaultPolicy.Validate(fl.Field().String(), terms...) == nil
+	})
 	return vd
 }
 
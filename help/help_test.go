@@ -24,3 +24,33 @@ func TestIsEmpty(t *testing.T) {
 	var b struct{}
 	assert.True(t, help.IsEmpty(b))
 }
+
+type Account struct {
+	Name     string
+	Email    string
+	Password string `vd:"password=Name Email"`
+}
+
+func TestValidatorPassword(t *testing.T) {
+	vd := help.Validator()
+	assert.NoError(t, vd.ValidateStruct(&Account{
+		Name:     "kain",
+		Email:    "kain@example.com",
+		Password: "xK9#mQ2$vL7!",
+	}))
+	assert.Error(t, vd.ValidateStruct(&Account{
+		Name:     "kain",
+		Email:    "kain@example.com",
+		Password: "password123",
+	}))
+	assert.Error(t, vd.ValidateStruct(&Account{
+		Name:     "kain",
+		Email:    "weplanx.kain@example.com",
+		Password: "xK9#Weplanx2$vL7!",
+	}))
+	assert.Error(t, vd.ValidateStruct(&Account{
+		Name:     "kain",
+		Email:    "kain@example.com",
+		Password: "xK9#",
+	}))
+}
@@ -20,16 +20,21 @@ func (x *Locker) Key(name string) string {
 	return fmt.Sprintf(`locker:%s`, name)
 }
 
+var update = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// Update counts atomically, so concurrent callers never share a value.
 func (x *Locker) Update(ctx context.Context, name string, ttl time.Duration) int64 {
-	key := x.Key(name)
-	count := x.RDb.Exists(ctx, key).Val()
-	if count != 0 {
-		return x.RDb.Incr(ctx, key).Val()
-	}
-	if x.RDb.Set(ctx, key, 1, ttl).Val() == "OK" {
-		return 1
+	n, err := update.Run(ctx, x.RDb, []string{x.Key(name)}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0
 	}
-	return 0
+	return n
 }
 
 var (
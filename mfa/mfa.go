@@ -0,0 +1,167 @@
+package mfa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/redis/go-redis/v9"
+	"github.com/weplanx/go/help"
+	"github.com/weplanx/go/locker"
+	"github.com/weplanx/go/totp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingCode = help.E("mfa.missing", "one-time password is required")
+	ErrInvalidCode = help.E("mfa.invalid", "one-time password is invalid")
+	ErrLocked      = help.E("mfa.locked", "too many one-time password attempts")
+)
+
+type Resolver func(ctx context.Context, c *app.RequestContext) (*totp.Totp, error)
+
+type Persist func(ctx context.Context, c *app.RequestContext, data *totp.Totp) error
+
+type StepUp struct {
+	RDb        *redis.Client
+	Resolver   Resolver
+	Persist    Persist
+	HeaderName string
+	FieldName  string
+	TTL        time.Duration
+}
+
+func New(rdb *redis.Client, resolver Resolver, options ...Option) *StepUp {
+	x := &StepUp{
+		RDb:        rdb,
+		Resolver:   resolver,
+		HeaderName: "X-OTP",
+		FieldName:  "otp",
+		TTL:        time.Minute * 5,
+	}
+	for _, v := range options {
+		v(x)
+	}
+	return x
+}
+
+type Option func(x *StepUp)
+
+func SetPersist(v Persist) Option {
+	return func(x *StepUp) {
+		x.Persist = v
+	}
+}
+
+func SetHeaderName(v string) Option {
+	return func(x *StepUp) {
+		x.HeaderName = v
+	}
+}
+
+func SetFieldName(v string) Option {
+	return func(x *StepUp) {
+		x.FieldName = v
+	}
+}
+
+func SetTTL(v time.Duration) Option {
+	return func(x *StepUp) {
+		x.TTL = v
+	}
+}
+
+func (x *StepUp) Key(subject string) string {
+	return fmt.Sprintf(`mfa:%s`, subject)
+}
+
+func (x *StepUp) Satisfied(ctx context.Context, subject string) bool {
+	return x.RDb.Exists(ctx, x.Key(subject)).Val() != 0
+}
+
+func (x *StepUp) Mark(ctx context.Context, subject string) error {
+	return x.RDb.Set(ctx, x.Key(subject), time.Now().Unix(), x.TTL).Err()
+}
+
+func (x *StepUp) Revoke(ctx context.Context, subject string) int64 {
+	return x.RDb.Del(ctx, x.Key(subject)).Val()
+}
+
+func (x *StepUp) Code(c *app.RequestContext) string {
+	if v := c.GetHeader(x.HeaderName); len(v) != 0 {
+		return string(v)
+	}
+	if strings.Contains(string(c.ContentType()), "json") {
+		var body map[string]interface{}
+		if err := json.Unmarshal(c.Request.Body(), &body); err == nil {
+			if v, ok := body[x.FieldName].(string); ok {
+				return v
+			}
+		}
+		return ""
+	}
+	return c.PostForm(x.FieldName)
+}
+
+func (x *StepUp) Verify() app.HandlerFunc {
+	return func(ctx context.Context, c *app.RequestContext) {
+		data, err := x.Resolver(ctx, c)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		// Unknown subjects are refused like a wrong code.
+		if data == nil {
+			c.Error(ErrInvalidCode)
+			c.Abort()
+			return
+		}
+		if data.Subject == "" {
+			c.Error(totp.ErrMissingSubject)
+			c.Abort()
+			return
+		}
+		if x.Satisfied(ctx, data.Subject) {
+			c.Next(ctx)
+			return
+		}
+
+		code := x.Code(c)
+		if code == "" {
+			c.Error(ErrMissingCode)
+			c.Abort()
+			return
+		}
+		ok, err := data.AuthenticateContext(ctx, code)
+		if err != nil && !errors.Is(err, totp.ErrNotMatch) {
+			if errors.Is(err, locker.ErrLocked) {
+				err = ErrLocked
+			}
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if !ok {
+			c.Error(ErrInvalidCode)
+			c.Abort()
+			return
+		}
+
+		if x.Persist != nil {
+			if err = x.Persist(ctx, c, data); err != nil {
+				c.Error(err)
+				c.Abort()
+				return
+			}
+		}
+		if err = x.Mark(ctx, data.Subject); err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Next(ctx)
+	}
+}
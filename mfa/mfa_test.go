@@ -0,0 +1,136 @@
+package mfa_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/cloudwego/hertz/pkg/common/config"
+	"github.com/cloudwego/hertz/pkg/common/ut"
+	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/cloudwego/hertz/pkg/route"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/help"
+	"github.com/weplanx/go/locker"
+	"github.com/weplanx/go/mfa"
+	"github.com/weplanx/go/totp"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+var x *mfa.StepUp
+var engine *route.Engine
+var users map[string]*totp.Totp
+var persisted int
+
+func TestMain(m *testing.M) {
+	opts, err := redis.ParseURL(os.Getenv("DATABASE_REDIS"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rdb := redis.NewClient(opts)
+	guard := totp.NewGuard(locker.New(rdb), 3, time.Minute)
+	users = map[string]*totp.Totp{
+		"alice": {Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Subject: "alice", Guard: guard},
+		"bob":   {Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Subject: "bob", Guard: guard},
+		"eve":   {Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Subject: "eve", Guard: guard},
+	}
+	ctx := context.TODO()
+	for k := range users {
+		guard.Reset(ctx, k)
+	}
+	x = mfa.New(rdb, func(ctx context.Context, c *app.RequestContext) (*totp.Totp, error) {
+		return users[c.Query("user")], nil
+	},
+		mfa.SetPersist(func(ctx context.Context, c *app.RequestContext, data *totp.Totp) error {
+			persisted++
+			return nil
+		}),
+		mfa.SetTTL(time.Minute),
+	)
+	for k := range users {
+		x.Revoke(ctx, k)
+	}
+
+	engine = route.NewEngine(config.NewOptions(nil))
+	engine.Use(help.EHandler())
+	engine.POST("/payout", x.Verify(), func(ctx context.Context, c *app.RequestContext) {
+		c.JSON(200, utils.H{"ok": 1})
+	})
+	os.Exit(m.Run())
+}
+
+func code() string {
+	return fmt.Sprintf("%06d", totp.Compute("2SH3V3GDW7ZNMGYE", time.Now().Unix()/30))
+}
+
+func decode(t *testing.T, w *ut.ResponseRecorder) (r utils.H) {
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &r))
+	return
+}
+
+func TestVerifyMissing(t *testing.T) {
+	w := ut.PerformRequest(engine, "POST", "/payout?user=alice", nil)
+	resp := w.Result()
+	assert.Equal(t, 400, resp.StatusCode())
+	assert.Equal(t, "mfa.missing", decode(t, w)["code"])
+}
+
+func TestVerifyHeader(t *testing.T) {
+	w := ut.PerformRequest(engine, "POST", "/payout?user=alice", nil,
+		ut.Header{Key: "X-OTP", Value: "000000"})
+	assert.Equal(t, 400, w.Result().StatusCode())
+	assert.Equal(t, "mfa.invalid", decode(t, w)["code"])
+
+	w = ut.PerformRequest(engine, "POST", "/payout?user=alice", nil,
+		ut.Header{Key: "X-OTP", Value: code()})
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Equal(t, 1, persisted)
+	assert.True(t, x.Satisfied(context.TODO(), "alice"))
+
+	// The marker lets follow-up requests through without a code.
+	w = ut.PerformRequest(engine, "POST", "/payout?user=alice", nil)
+	assert.Equal(t, 200, w.Result().StatusCode())
+	assert.Equal(t, 1, persisted)
+	assert.Equal(t, int64(1), x.Revoke(context.TODO(), "alice"))
+}
+
+func TestVerifyBody(t *testing.T) {
+	body := []byte(fmt.Sprintf(`{"otp":"%s"}`, code()))
+	w := ut.PerformRequest(engine, "POST", "/payout?user=bob",
+		&ut.Body{Body: bytes.NewReader(body), Len: len(body)},
+		ut.Header{Key: "Content-Type", Value: "application/json"})
+	assert.Equal(t, 200, w.Result().StatusCode())
+	x.Revoke(context.TODO(), "bob")
+
+	form := []byte("otp=" + code())
+	w = ut.PerformRequest(engine, "POST", "/payout?user=bob",
+		&ut.Body{Body: bytes.NewReader(form), Len: len(form)},
+		ut.Header{Key: "Content-Type", Value: "application/x-www-form-urlencoded"})
+	assert.Equal(t, 200, w.Result().StatusCode())
+	x.Revoke(context.TODO(), "bob")
+}
+
+func TestVerifyUnknown(t *testing.T) {
+	w := ut.PerformRequest(engine, "POST", "/payout?user=mallory", nil,
+		ut.Header{Key: "X-OTP", Value: code()})
+	assert.Equal(t, 400, w.Result().StatusCode())
+	assert.Equal(t, "mfa.invalid", decode(t, w)["code"])
+}
+
+func TestVerifyLocked(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		w := ut.PerformRequest(engine, "POST", "/payout?user=eve", nil,
+			ut.Header{Key: "X-OTP", Value: "000000"})
+		assert.Equal(t, "mfa.invalid", decode(t, w)["code"])
+	}
+	w := ut.PerformRequest(engine, "POST", "/payout?user=eve", nil,
+		ut.Header{Key: "X-OTP", Value: code()})
+	assert.Equal(t, 400, w.Result().StatusCode())
+	assert.Equal(t, "mfa.locked", decode(t, w)["code"])
+	assert.False(t, x.Satisfied(context.TODO(), "eve"))
+}
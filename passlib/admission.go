@@ -0,0 +1,90 @@
+package passlib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrSaturated = errors.New("too many concurrent password hashing requests")
+)
+
+type Stats struct {
+	Concurrency int
+	QueueSize   int
+	Running     int64
+	Waiting     int64
+	Completed   uint64
+	Rejected    uint64
+	Timeouts    uint64
+}
+
+type admission struct {
+	once      sync.Once
+	slots     chan struct{}
+	running   atomic.Int64
+	waiting   atomic.Int64
+	completed atomic.Uint64
+	rejected  atomic.Uint64
+	timeouts  atomic.Uint64
+}
+
+func (x *Hasher) acquire(ctx context.Context) (release func(), err error) {
+	x.admission.once.Do(func() {
+		if x.Concurrency > 0 {
+			x.admission.slots = make(chan struct{}, x.Concurrency)
+		}
+	})
+	a := &x.admission
+	release = func() {
+		if a.slots != nil {
+			<-a.slots
+		}
+		a.running.Add(-1)
+		a.completed.Add(1)
+	}
+	if a.slots == nil {
+		a.running.Add(1)
+		return
+	}
+	select {
+	case a.slots <- struct{}{}:
+		a.running.Add(1)
+		return
+	default:
+	}
+
+	if a.waiting.Add(1) > int64(x.QueueSize) {
+		a.waiting.Add(-1)
+		a.rejected.Add(1)
+		return nil, ErrSaturated
+	}
+	defer a.waiting.Add(-1)
+	if x.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x.Timeout)
+		defer cancel()
+	}
+	select {
+	case a.slots <- struct{}{}:
+		a.running.Add(1)
+		return
+	case <-ctx.Done():
+		a.timeouts.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+func (x *Hasher) Stats() Stats {
+	return Stats{
+		Concurrency: x.Concurrency,
+		QueueSize:   x.QueueSize,
+		Running:     x.admission.running.Load(),
+		Waiting:     x.admission.waiting.Load(),
+		Completed:   x.admission.completed.Load(),
+		Rejected:    x.admission.rejected.Load(),
+		Timeouts:    x.admission.timeouts.Load(),
+	}
+}
@@ -0,0 +1,103 @@
+package passlib_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"testing"
+	"time"
+)
+
+type blocking chan struct{}
+
+func (x blocking) Hash(password string) (string, error) {
+	<-x
+	return "$blocking$" + password, nil
+}
+
+func (x blocking) Verify(password string, hash string) error {
+	<-x
+	return nil
+}
+
+func TestAdmission(t *testing.T) {
+	gate := make(blocking)
+	passlib.Register("blocking", gate)
+	x := passlib.New(
+		passlib.SetAlgorithm("blocking"),
+		passlib.SetConcurrency(1),
+		passlib.SetQueueSize(1),
+	)
+
+	running := make(chan error)
+	go func() {
+		_, err := x.Hash("pass@VAN1234")
+		running <- err
+	}()
+	assert.Eventually(t, func() bool { return x.Stats().Running == 1 }, time.Second, time.Millisecond)
+
+	waiting := make(chan error)
+	go func() {
+		waiting <- x.VerifyContext(context.TODO(), "pass@VAN1234", "$blocking$pass@VAN1234")
+	}()
+	assert.Eventually(t, func() bool { return x.Stats().Waiting == 1 }, time.Second, time.Millisecond)
+
+	// The queue is full, so further callers are turned away immediately.
+	_, err := x.HashContext(context.TODO(), "pass@VAN1234")
+	assert.ErrorIs(t, err, passlib.ErrSaturated)
+
+	gate <- struct{}{}
+	assert.NoError(t, <-running)
+	gate <- struct{}{}
+	assert.NoError(t, <-waiting)
+
+	stats := x.Stats()
+	assert.Equal(t, 1, stats.Concurrency)
+	assert.Equal(t, 1, stats.QueueSize)
+	assert.Equal(t, int64(0), stats.Running)
+	assert.Equal(t, int64(0), stats.Waiting)
+	assert.Equal(t, uint64(2), stats.Completed)
+	assert.Equal(t, uint64(1), stats.Rejected)
+}
+
+func TestAdmissionTimeout(t *testing.T) {
+	gate := make(blocking)
+	passlib.Register("blocking", gate)
+	x := passlib.New(
+		passlib.SetAlgorithm("blocking"),
+		passlib.SetConcurrency(1),
+		passlib.SetQueueSize(10),
+		passlib.SetTimeout(time.Millisecond*20),
+	)
+
+	running := make(chan error)
+	go func() {
+		_, err := x.Hash("pass@VAN1234")
+		running <- err
+	}()
+	assert.Eventually(t, func() bool { return x.Stats().Running == 1 }, time.Second, time.Millisecond)
+
+	_, err := x.Hash("pass@VAN1234")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err = x.VerifyContext(ctx, "pass@VAN1234", "$blocking$pass@VAN1234")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, uint64(2), x.Stats().Timeouts)
+
+	gate <- struct{}{}
+	assert.NoError(t, <-running)
+	assert.Equal(t, int64(0), x.Stats().Waiting)
+}
+
+func TestAdmissionUnlimited(t *testing.T) {
+	x := passlib.New(passlib.SetMemory(8192), passlib.SetTime(1))
+	hash, err := x.HashContext(context.TODO(), "pass@VAN1234")
+	assert.NoError(t, err)
+	assert.NoError(t, x.VerifyContext(context.TODO(), "pass@VAN1234", hash))
+	assert.Equal(t, uint64(2), x.Stats().Completed)
+
+	_, err = passlib.HashContext(context.TODO(), "pass@VAN1234")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,175 @@
+package passlib
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strconv"
+	"strings"
+)
+
+type Argon2id struct {
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+	Pepper     string
+	Peppers    map[string][]byte
+}
+
+func (x *Argon2id) params() (memory uint32, time uint32, threads uint8) {
+	memory, time, threads = x.Memory, x.Time, x.Threads
+	if memory == 0 {
+		memory = DefaultMemoryCost
+	}
+	if time == 0 {
+		time = DefaultTimeCost
+	}
+	if threads == 0 {
+		threads = DefaultThreads
+	}
+	return
+}
+
+func (x *Argon2id) lengths() (salt uint32, key uint32) {
+	salt, key = x.SaltLength, x.KeyLength
+	if salt == 0 {
+		salt = DefaultSaltLength
+	}
+	if key == 0 {
+		key = DefaultKeyLength
+	}
+	return
+}
+
+func (x *Argon2id) pepper() (string, map[string][]byte) {
+	if x.Peppers == nil {
+		return DefaultPepper, Peppers
+	}
+	return x.Pepper, x.Peppers
+}
+
+func (x *Argon2id) Hash(password string) (hash string, err error) {
+	saltLength, keyLength := x.lengths()
+	salt := make([]byte, saltLength)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	id, peppers := x.pepper()
+	var input []byte
+	if input, err = pepper(peppers, password, id); err != nil {
+		return
+	}
+	memory, time, threads := x.params()
+	key := argon2.IDKey(input, salt, time, memory, threads, keyLength)
+
+	params := fmt.Sprintf(`m=%d,t=%d,p=%d`, memory, time, threads)
+	if id != "" {
+		params += ",keyid=" + id
+	}
+	return fmt.Sprintf(`$argon2id$v=%d$%s$%s$%s`,
+		argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	keyId   string
+	salt    []byte
+	key     []byte
+}
+
+func decodeArgon2id(hash string) (h *argon2idHash, err error) {
+	options := strings.Split(hash, "$")
+	if len(options) != 6 {
+		return nil, ErrInvalidHash
+	}
+	if options[1] != "argon2id" {
+		return nil, ErrIncompatibleVariant
+	}
+	var version int
+	if _, err = fmt.Sscanf(options[2], "v=%d", &version); err != nil {
+		return nil, ErrIncompatibleVersion
+	}
+	if version != argon2.Version {
+		return nil, ErrIncompatibleVersion
+	}
+	h = new(argon2idHash)
+	for _, v := range strings.Split(options[3], ",") {
+		name, value, _ := strings.Cut(v, "=")
+		var n uint64
+		switch name {
+		case "m":
+			n, err = strconv.ParseUint(value, 10, 32)
+			h.memory = uint32(n)
+		case "t":
+			n, err = strconv.ParseUint(value, 10, 32)
+			h.time = uint32(n)
+		case "p":
+			n, err = strconv.ParseUint(value, 10, 8)
+			h.threads = uint8(n)
+		case "keyid":
+			h.keyId = value
+		default:
+			return nil, ErrInvalidHash
+		}
+		if err != nil {
+			return nil, ErrInvalidHash
+		}
+	}
+	if h.memory == 0 || h.time == 0 || h.threads == 0 {
+		return nil, ErrInvalidHash
+	}
+	if h.salt, err = base64.RawStdEncoding.Strict().DecodeString(options[4]); err != nil {
+		return
+	}
+	if h.key, err = base64.RawStdEncoding.Strict().DecodeString(options[5]); err != nil {
+		return
+	}
+	if len(h.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+	return
+}
+
+func (x *Argon2id) Verify(password string, hash string) (err error) {
+	var h *argon2idHash
+	if h, err = decodeArgon2id(hash); err != nil {
+		return
+	}
+	// Hashes keep the pepper they were made with until rehashed.
+	_, peppers := x.pepper()
+	var input []byte
+	if input, err = pepper(peppers, password, h.keyId); err != nil {
+		return
+	}
+	otherKey := argon2.IDKey(input, h.salt, h.time, h.memory, h.threads, uint32(len(h.key)))
+	if subtle.ConstantTimeEq(int32(len(h.key)), int32(len(otherKey))) == 0 {
+		return ErrNotMatch
+	}
+	if subtle.ConstantTimeCompare(h.key, otherKey) == 1 {
+		return
+	}
+	return ErrNotMatch
+}
+
+func (x *Argon2id) NeedsRehash(hash string) bool {
+	h, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	if id, _ := x.pepper(); h.keyId != id {
+		return true
+	}
+	m, t, p := x.params()
+	salt, key := x.lengths()
+	return h.memory < m || h.time < t || h.threads < p ||
+		uint32(len(h.salt)) < salt || uint32(len(h.key)) < key
+}
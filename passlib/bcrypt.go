@@ -0,0 +1,45 @@
+package passlib
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Bcrypt struct {
+	Cost int
+}
+
+func (x *Bcrypt) cost() int {
+	if x.Cost == 0 {
+		return DefaultBcryptCost
+	}
+	return x.Cost
+}
+
+func (x *Bcrypt) Hash(password string) (_ string, err error) {
+	var b []byte
+	if b, err = bcrypt.GenerateFromPassword([]byte(password), x.cost()); err != nil {
+		return
+	}
+	return string(b), nil
+}
+
+func (x *Bcrypt) Verify(password string, hash string) (err error) {
+	// $2a$, $2b$ and $2y$ only differ in how older implementations
+	// handled non-ascii passwords, the comparison is identical.
+	if err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrNotMatch
+		}
+		return ErrInvalidHash
+	}
+	return
+}
+
+func (x *Bcrypt) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return true
+	}
+	return cost < x.cost()
+}
@@ -0,0 +1,32 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"testing"
+)
+
+func TestBcrypt(t *testing.T) {
+	x := &passlib.Bcrypt{Cost: 4}
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$2a$04$")
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, x.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	assert.False(t, x.NeedsRehash(hash))
+	assert.True(t, (&passlib.Bcrypt{Cost: 5}).NeedsRehash(hash))
+	assert.True(t, x.NeedsRehash("$2a$"))
+}
+
+func TestBcryptVariants(t *testing.T) {
+	for _, hash := range []string{
+		`$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`,
+		`$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`,
+		`$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`,
+	} {
+		assert.NoError(t, passlib.Verify("U*U", hash))
+		assert.ErrorIs(t, passlib.Verify("U*V", hash), passlib.ErrNotMatch)
+	}
+	err := passlib.Verify("U*U", `$2a$05$CCCCCCCCC`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+}
@@ -0,0 +1,221 @@
+package passlib
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidFilter = errors.New("the breach filter is invalid")
+	ErrInvalidDump   = errors.New("the breach dump is invalid")
+)
+
+// Checker reports whether a password is known to be compromised.
+type Checker interface {
+	Breached(password string) (bool, error)
+}
+
+func digest(password string) [sha1.Size]byte {
+	return sha1.Sum([]byte(password))
+}
+
+// Corpus reads a HIBP-style range directory, each file is named by the first
+// 5 hex characters of the SHA-1 and holds SUFFIX:COUNT lines.
+type Corpus struct {
+	Dir      string
+	MinCount uint64
+}
+
+func NewCorpus(dir string) *Corpus {
+	return &Corpus{Dir: dir}
+}
+
+func (x *Corpus) Breached(password string) (_ bool, err error) {
+	d := digest(password)
+	sum := strings.ToUpper(hex.EncodeToString(d[:]))
+	prefix, suffix := sum[:5], sum[5:]
+	var f *os.File
+	if f, err = os.Open(filepath.Join(x.Dir, prefix+".txt")); errors.Is(err, os.ErrNotExist) {
+		f, err = os.Open(filepath.Join(x.Dir, prefix))
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(hash, suffix) {
+			continue
+		}
+		if x.MinCount == 0 {
+			return true, nil
+		}
+		var n uint64
+		if n, err = strconv.ParseUint(count, 10, 64); err != nil {
+			return false, ErrInvalidDump
+		}
+		return n >= x.MinCount, nil
+	}
+	return false, scanner.Err()
+}
+
+var filterMagic = [4]byte{'p', 'l', 'b', 'f'}
+
+const maxFilterBits uint64 = 1 << 37
+
+// Filter is a Bloom filter over SHA-1 digests, so false positives are
+// possible at the rate chosen when it was built but false negatives are not.
+type Filter struct {
+	k    uint32
+	bits []uint64
+}
+
+// NewFilter sizes a filter for n entries at the false positive rate p.
+func NewFilter(n uint64, p float64) (*Filter, error) {
+	if n == 0 || p <= 0 || p >= 1 {
+		return nil, ErrInvalidFilter
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	if m > float64(maxFilterBits) {
+		return nil, ErrInvalidFilter
+	}
+	words := (uint64(m) + 63) / 64
+	k := math.Round(float64(words*64) / float64(n) * math.Ln2)
+	return &Filter{
+		k:    uint32(min(max(k, 1), 32)),
+		bits: make([]uint64, words),
+	}, nil
+}
+
+// The digest is already uniform, so its halves seed double hashing.
+func (x *Filter) locations(d [sha1.Size]byte, fn func(word int, mask uint64) bool) bool {
+	m := uint64(len(x.bits)) * 64
+	h1 := binary.LittleEndian.Uint64(d[0:8])
+	h2 := binary.LittleEndian.Uint64(d[8:16]) | 1
+	for i := uint64(0); i < uint64(x.k); i++ {
+		v := (h1 + i*h2) % m
+		if !fn(int(v/64), 1<<(v%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (x *Filter) add(d [sha1.Size]byte) {
+	x.locations(d, func(word int, mask uint64) bool {
+		x.bits[word] |= mask
+		return true
+	})
+}
+
+func (x *Filter) Add(password string) {
+	x.add(digest(password))
+}
+
+func (x *Filter) Breached(password string) (bool, error) {
+	return x.locations(digest(password), func(word int, mask uint64) bool {
+		return x.bits[word]&mask != 0
+	}), nil
+}
+
+// BuildFilter reads a text dump of HASH:COUNT lines, as published ordered by
+// hash, and skips entries seen fewer than minCount times.
+func BuildFilter(r io.Reader, n uint64, p float64, minCount uint64) (x *Filter, err error) {
+	if x, err = NewFilter(n, p); err != nil {
+		return
+	}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hash, count, found := strings.Cut(text, ":")
+		var d [sha1.Size]byte
+		if len(hash) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidDump, line)
+		}
+		if _, err = hex.Decode(d[:], []byte(hash)); err != nil {
+			return nil, fmt.Errorf("%w: line %d", ErrInvalidDump, line)
+		}
+		if found && minCount > 0 {
+			var c uint64
+			if c, err = strconv.ParseUint(count, 10, 64); err != nil {
+				return nil, fmt.Errorf("%w: line %d", ErrInvalidDump, line)
+			}
+			if c < minCount {
+				continue
+			}
+		}
+		x.add(d)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	bw := bufio.NewWriter(w)
+	header := make([]byte, 16)
+	copy(header, filterMagic[:])
+	binary.LittleEndian.PutUint32(header[4:], x.k)
+	binary.LittleEndian.PutUint64(header[8:], uint64(len(x.bits))*64)
+	if _, err = bw.Write(header); err != nil {
+		return
+	}
+	n = int64(len(header))
+	buf := make([]byte, 8)
+	for _, v := range x.bits {
+		binary.LittleEndian.PutUint64(buf, v)
+		if _, err = bw.Write(buf); err != nil {
+			return
+		}
+		n += 8
+	}
+	err = bw.Flush()
+	return
+}
+
+func ReadFilter(r io.Reader) (_ *Filter, err error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 16)
+	if _, err = io.ReadFull(br, header); err != nil {
+		return nil, ErrInvalidFilter
+	}
+	k := binary.LittleEndian.Uint32(header[4:])
+	m := binary.LittleEndian.Uint64(header[8:])
+	if [4]byte(header[:4]) != filterMagic || k == 0 || k > 32 ||
+		m == 0 || m%64 != 0 || m > maxFilterBits {
+		return nil, ErrInvalidFilter
+	}
+	x := &Filter{k: k, bits: make([]uint64, m/64)}
+	buf := make([]byte, 8)
+	for i := range x.bits {
+		if _, err = io.ReadFull(br, buf); err != nil {
+			return nil, ErrInvalidFilter
+		}
+		x.bits[i] = binary.LittleEndian.Uint64(buf)
+	}
+	return x, nil
+}
+
+func OpenFilter(name string) (_ *Filter, err error) {
+	var f *os.File
+	if f, err = os.Open(name); err != nil {
+		return
+	}
+	defer f.Close()
+	return ReadFilter(f)
+}
@@ -0,0 +1,115 @@
+package passlib_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var breached = map[string]int{
+	"password":    9545824,
+	"123456":      37359195,
+	"qwerty":      10556095,
+	"Tr0ub4dor&3": 2,
+}
+
+func sum(password string) string {
+	d := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(d[:]))
+}
+
+func TestCorpus(t *testing.T) {
+	dir := t.TempDir()
+	for password, count := range breached {
+		v := sum(password)
+		// Ranges also hold other suffixes, lowercase and CRLF in the wild.
+		content := fmt.Sprintf("0000000000000000000000000000000000A:1\r\n%s:%d\r\n", strings.ToLower(v[5:]), count)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, v[:5]+".txt"), []byte(content), 0644))
+	}
+	v := sum("pass@VAN1234")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, v[:5]), []byte("0000000000000000000000000000000000A:1\n"), 0644))
+
+	x := passlib.NewCorpus(dir)
+	for password := range breached {
+		ok, err := x.Breached(password)
+		assert.NoError(t, err)
+		assert.True(t, ok, password)
+	}
+	ok, err := x.Breached("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	x.MinCount = 10
+	ok, err = x.Breached("Tr0ub4dor&3")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = x.Breached("password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = x.Breached("xK9#mQ2$vL7!")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFilter(t *testing.T) {
+	var dump strings.Builder
+	for password, count := range breached {
+		fmt.Fprintf(&dump, "%s:%d\n", sum(password), count)
+	}
+	dump.WriteString("\n")
+	x, err := passlib.BuildFilter(strings.NewReader(dump.String()), 4, 0.001, 10)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := x.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	name := filepath.Join(t.TempDir(), "breached.bin")
+	assert.NoError(t, os.WriteFile(name, buf.Bytes(), 0644))
+	x, err = passlib.OpenFilter(name)
+	assert.NoError(t, err)
+	for password, count := range breached {
+		ok, err := x.Breached(password)
+		assert.NoError(t, err)
+		assert.Equal(t, count >= 10, ok, password)
+	}
+
+	_, err = passlib.ReadFilter(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, passlib.ErrInvalidFilter)
+	_, err = passlib.ReadFilter(strings.NewReader("plbf"))
+	assert.ErrorIs(t, err, passlib.ErrInvalidFilter)
+
+	_, err = passlib.BuildFilter(strings.NewReader("password:1\n"), 4, 0.001, 0)
+	assert.ErrorIs(t, err, passlib.ErrInvalidDump)
+	_, err = passlib.BuildFilter(strings.NewReader(sum("password")+":many\n"), 4, 0.001, 10)
+	assert.ErrorIs(t, err, passlib.ErrInvalidDump)
+	_, err = passlib.NewFilter(0, 0.001)
+	assert.ErrorIs(t, err, passlib.ErrInvalidFilter)
+	_, err = passlib.NewFilter(4, 1)
+	assert.ErrorIs(t, err, passlib.ErrInvalidFilter)
+}
+
+func TestFilterFalsePositives(t *testing.T) {
+	x, err := passlib.NewFilter(10000, 0.01)
+	assert.NoError(t, err)
+	for i := 0; i < 10000; i++ {
+		x.Add(fmt.Sprintf("breached-%d", i))
+	}
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		ok, _ := x.Breached(fmt.Sprintf("breached-%d", i))
+		assert.True(t, ok)
+		if ok, _ = x.Breached(fmt.Sprintf("unseen-%d", i)); ok {
+			positives++
+		}
+	}
+	assert.Less(t, positives, 200)
+}
@@ -0,0 +1,75 @@
+package passlib
+
+import (
+	"crypto/rand"
+	"errors"
+	"golang.org/x/crypto/argon2"
+	"time"
+)
+
+var (
+	ErrInvalidCalibration = errors.New("calibration target or memory ceiling is too small")
+)
+
+const (
+	minCalibrationMemory uint32 = 8192
+	maxCalibrationTime   uint32 = 64
+)
+
+type Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+func (x Params) Options() []Option {
+	return []Option{SetMemory(x.Memory), SetTime(x.Time), SetThreads(x.Threads)}
+}
+
+// Calibrate benchmarks argon2id on this machine, memory is in KiB like
+// DefaultMemoryCost. When even the smallest memory misses the target the
+// cheapest parameters are returned.
+func Calibrate(target time.Duration, maxMemory uint32, threads uint8) (params Params, err error) {
+	if threads == 0 {
+		threads = 1
+	}
+	// Argon2 needs at least 8 KiB for every lane.
+	if target <= 0 || maxMemory < 8*uint32(threads) {
+		return params, ErrInvalidCalibration
+	}
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	measure := func(p Params) time.Duration {
+		// The fastest of two runs filters out scheduling noise.
+		var d time.Duration
+		for i := 0; i < 2; i++ {
+			start := time.Now()
+			argon2.IDKey([]byte("calibration"), salt, p.Time, p.Memory, p.Threads, 32)
+			if elapsed := time.Since(start); i == 0 || elapsed < d {
+				d = elapsed
+			}
+		}
+		return d
+	}
+
+	// Memory is preferred over passes, it is what hurts parallel attackers.
+	floor := max(min(minCalibrationMemory, maxMemory), 8*uint32(threads))
+	params = Params{Memory: maxMemory, Time: 1, Threads: threads}
+	d := measure(params)
+	for d > target && params.Memory/2 >= floor {
+		params.Memory /= 2
+		d = measure(params)
+	}
+	if d >= target {
+		return
+	}
+
+	// Cost grows linearly with passes, so estimate and then step back.
+	params.Time = min(uint32(target/max(d, 1)), maxCalibrationTime)
+	for params.Time > 1 && measure(params) > target {
+		params.Time--
+	}
+	return
+}
@@ -0,0 +1,46 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalibrate(t *testing.T) {
+	params, err := passlib.Calibrate(time.Millisecond*50, 16384, 2)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, params.Memory, uint32(16384))
+	assert.GreaterOrEqual(t, params.Memory, uint32(8192))
+	assert.GreaterOrEqual(t, params.Time, uint32(1))
+	assert.Equal(t, uint8(2), params.Threads)
+	t.Log(params)
+
+	x := passlib.New(params.Options()...)
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$"))
+	assert.False(t, x.NeedsRehash(hash))
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+}
+
+func TestCalibrateUnreachable(t *testing.T) {
+	// Far below what argon2 can do, so the cheapest parameters are returned.
+	params, err := passlib.Calibrate(time.Nanosecond, 65536, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, passlib.Params{Memory: 8192, Time: 1, Threads: 1}, params)
+
+	params, err = passlib.Calibrate(time.Nanosecond, 1024, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, passlib.Params{Memory: 1024, Time: 1, Threads: 1}, params)
+}
+
+func TestCalibrateErrors(t *testing.T) {
+	_, err := passlib.Calibrate(0, 65536, 1)
+	assert.ErrorIs(t, err, passlib.ErrInvalidCalibration)
+	_, err = passlib.Calibrate(time.Second, 0, 1)
+	assert.ErrorIs(t, err, passlib.ErrInvalidCalibration)
+	_, err = passlib.Calibrate(time.Second, 16, 4)
+	assert.ErrorIs(t, err, passlib.ErrInvalidCalibration)
+}
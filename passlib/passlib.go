@@ -1,78 +1,313 @@
 package passlib
 
 import (
-	"crypto/rand"
-	"crypto/subtle"
-	"encoding/base64"
+	"context"
 	"errors"
-	"fmt"
-	"golang.org/x/crypto/argon2"
 	"strings"
+	"sync"
+	"time"
 )
 
 var (
-	DefaultMemoryCost uint32 = 65536
-	DefaultTimeCost   uint32 = 4
-	DefaultThreads    uint8  = 1
+	DefaultMemoryCost   uint32 = 65536
+	DefaultTimeCost     uint32 = 4
+	DefaultThreads      uint8  = 1
+	DefaultSaltLength   uint32 = 16
+	DefaultKeyLength    uint32 = 32
+	DefaultBcryptCost          = 12
+	DefaultScryptCost          = 15
+	DefaultPbkdf2Rounds        = 600000
+	DefaultAlgorithm           = "argon2id"
+	DefaultPepper              = ""
 )
 
+// Peppers are keyed by the id recorded as keyid in argon2id hashes,
+// older entries stay here until every hash using them was upgraded.
+var Peppers = map[string][]byte{}
+
 var (
 	ErrInvalidHash         = errors.New("unable to parse the current hash value")
 	ErrIncompatibleVariant = errors.New("hash variants are not compatible")
 	ErrIncompatibleVersion = errors.New("hash version are not support")
 	ErrNotMatch            = errors.New("password does not match hash")
+	ErrPepperNotExists     = errors.New("the pepper does not exists")
 )
 
-func Hash(password string) (hash string, err error) {
-	salt := make([]byte, 16)
-	if _, err = rand.Read(salt); err != nil {
-		return
+type Algorithm interface {
+	Hash(password string) (string, error)
+	Verify(password string, hash string) error
+}
+
+type Rehasher interface {
+	NeedsRehash(hash string) bool
+}
+
+var (
+	argon2idAlgorithm = &Argon2id{}
+	bcryptAlgorithm   = &Bcrypt{}
+)
+
+var (
+	mu         sync.RWMutex
+	algorithms = map[string]Algorithm{
+		"argon2id":      argon2idAlgorithm,
+		"2a":            bcryptAlgorithm,
+		"2b":            bcryptAlgorithm,
+		"2y":            bcryptAlgorithm,
+		"scrypt":        &Scrypt{},
+		"pbkdf2-sha256": &Pbkdf2{},
 	}
-	key := argon2.IDKey([]byte(password), salt,
-		DefaultTimeCost, DefaultMemoryCost, DefaultThreads, 32)
+)
 
-	return fmt.Sprintf(`$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s`,
-		argon2.Version, DefaultMemoryCost, DefaultTimeCost, DefaultThreads,
-		base64.RawStdEncoding.EncodeToString(salt),
-		base64.RawStdEncoding.EncodeToString(key),
-	), nil
+// Register adds or replaces the algorithm for id, replacing argon2id
+// also takes precedence over the argon2id settings of a Hasher.
+func Register(id string, algorithm Algorithm) {
+	mu.Lock()
+	defer mu.Unlock()
+	algorithms[id] = algorithm
 }
 
-func Verify(password string, hash string) (err error) {
-	options := strings.Split(hash, "$")
-	if len(options) != 6 {
-		return ErrInvalidHash
+func Lookup(id string) (algorithm Algorithm, ok bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	algorithm, ok = algorithms[id]
+	return
+}
+
+func Identify(hash string) (string, error) {
+	if !strings.HasPrefix(hash, "$") {
+		return "", ErrInvalidHash
 	}
-	if options[1] != "argon2id" {
-		return ErrIncompatibleVariant
+	id, _, ok := strings.Cut(hash[1:], "$")
+	if !ok || id == "" {
+		return "", ErrInvalidHash
+	}
+	return id, nil
+}
+
+type Hasher struct {
+	Memory     uint32
+	Time       uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+	Pepper     string
+	Peppers    map[string][]byte
+	Algorithm  string
+
+	Concurrency int
+	QueueSize   int
+	Timeout     time.Duration
+
+	admission admission
+}
+
+// The zero value follows the package level defaults.
+var defaultHasher = new(Hasher)
+
+func New(options ...Option) *Hasher {
+	x := &Hasher{
+		Memory:     DefaultMemoryCost,
+		Time:       DefaultTimeCost,
+		Threads:    DefaultThreads,
+		SaltLength: DefaultSaltLength,
+		KeyLength:  DefaultKeyLength,
+		Algorithm:  DefaultAlgorithm,
+	}
+	for _, v := range options {
+		v(x)
+	}
+	return x
+}
+
+type Option func(x *Hasher)
+
+func SetMemory(v uint32) Option {
+	return func(x *Hasher) {
+		x.Memory = v
+	}
+}
+
+func SetTime(v uint32) Option {
+	return func(x *Hasher) {
+		x.Time = v
+	}
+}
+
+func SetThreads(v uint8) Option {
+	return func(x *Hasher) {
+		x.Threads = v
+	}
+}
+
+func SetSaltLength(v uint32) Option {
+	return func(x *Hasher) {
+		x.SaltLength = v
+	}
+}
+
+func SetKeyLength(v uint32) Option {
+	return func(x *Hasher) {
+		x.KeyLength = v
+	}
+}
+
+// SetPepper makes id the current pepper, earlier ones are kept for verification.
+func SetPepper(id string, secret []byte) Option {
+	return func(x *Hasher) {
+		if x.Peppers == nil {
+			x.Peppers = make(map[string][]byte)
+		}
+		x.Peppers[id] = secret
+		x.Pepper = id
+	}
+}
+
+func SetAlgorithm(v string) Option {
+	return func(x *Hasher) {
+		x.Algorithm = v
+	}
+}
+
+// SetConcurrency limits the hashes computed at the same time, 0 means unlimited.
+func SetConcurrency(v int) Option {
+	return func(x *Hasher) {
+		x.Concurrency = v
+	}
+}
+
+// SetQueueSize limits the callers waiting for a free slot, beyond which
+// ErrSaturated is returned, 0 rejects as soon as all slots are busy.
+func SetQueueSize(v int) Option {
+	return func(x *Hasher) {
+		x.QueueSize = v
 	}
-	var version int
-	if _, err = fmt.Sscanf(options[2], "v=%d", &version); err != nil {
-		return ErrIncompatibleVersion
+}
+
+func SetTimeout(v time.Duration) Option {
+	return func(x *Hasher) {
+		x.Timeout = v
 	}
-	if version != argon2.Version {
-		return ErrIncompatibleVersion
+}
+
+func (x *Hasher) preferred() string {
+	if x.Algorithm == "" {
+		return DefaultAlgorithm
 	}
-	var memory uint32
-	var time uint32
-	var threads uint8
-	if _, err = fmt.Sscanf(options[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
-		return ErrInvalidHash
+	return x.Algorithm
+}
+
+func (x *Hasher) algorithm(id string) (Algorithm, bool) {
+	algorithm, ok := Lookup(id)
+	if !ok || algorithm != Algorithm(argon2idAlgorithm) {
+		return algorithm, ok
 	}
-	var salt []byte
-	if salt, err = base64.RawStdEncoding.Strict().DecodeString(options[4]); err != nil {
+	return &Argon2id{
+		Memory:     x.Memory,
+		Time:       x.Time,
+		Threads:    x.Threads,
+		SaltLength: x.SaltLength,
+		KeyLength:  x.KeyLength,
+		Pepper:     x.Pepper,
+		Peppers:    x.Peppers,
+	}, true
+}
+
+func (x *Hasher) Hash(password string) (string, error) {
+	return x.HashContext(context.Background(), password)
+}
+
+func (x *Hasher) HashContext(ctx context.Context, password string) (_ string, err error) {
+	algorithm, ok := x.algorithm(x.preferred())
+	if !ok {
+		return "", ErrIncompatibleVariant
+	}
+	var release func()
+	if release, err = x.acquire(ctx); err != nil {
 		return
 	}
-	var key []byte
-	if key, err = base64.RawStdEncoding.Strict().DecodeString(options[5]); err != nil {
+	defer release()
+	return algorithm.Hash(password)
+}
+
+func (x *Hasher) Verify(password string, hash string) error {
+	return x.VerifyContext(context.Background(), password, hash)
+}
+
+func (x *Hasher) VerifyContext(ctx context.Context, password string, hash string) (err error) {
+	var id string
+	if id, err = Identify(hash); err != nil {
+		return
+	}
+	algorithm, ok := x.algorithm(id)
+	if !ok {
+		return ErrIncompatibleVariant
+	}
+	var release func()
+	if release, err = x.acquire(ctx); err != nil {
 		return
 	}
-	otherKey := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
-	if subtle.ConstantTimeEq(int32(len(key)), int32(len(otherKey))) == 0 {
-		return ErrNotMatch
+	defer release()
+	return algorithm.Verify(password, hash)
+}
+
+func (x *Hasher) NeedsRehash(hash string) bool {
+	id, err := Identify(hash)
+	if err != nil {
+		return true
+	}
+	algorithm, ok := x.algorithm(id)
+	if !ok {
+		return true
+	}
+	if preferred := x.preferred(); id != preferred {
+		// Aliases such as $2b$ and $2y$ share one registered instance.
+		registered, _ := Lookup(id)
+		other, ok := Lookup(preferred)
+		if !ok || registered != other {
+			return true
+		}
+	}
+	if v, ok := algorithm.(Rehasher); ok {
+		return v.NeedsRehash(hash)
 	}
-	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
+	return false
+}
+
+func (x *Hasher) VerifyAndUpgrade(password string, hash string) (string, error) {
+	return x.VerifyAndUpgradeContext(context.Background(), password, hash)
+}
+
+func (x *Hasher) VerifyAndUpgradeContext(ctx context.Context, password string, hash string) (newHash string, err error) {
+	if err = x.VerifyContext(ctx, password, hash); err != nil {
 		return
 	}
-	return ErrNotMatch
+	if x.NeedsRehash(hash) {
+		return x.HashContext(ctx, password)
+	}
+	return
+}
+
+func Hash(password string) (string, error) {
+	return defaultHasher.Hash(password)
+}
+
+func HashContext(ctx context.Context, password string) (string, error) {
+	return defaultHasher.HashContext(ctx, password)
+}
+
+func Verify(password string, hash string) error {
+	return defaultHasher.Verify(password, hash)
+}
+
+func VerifyContext(ctx context.Context, password string, hash string) error {
+	return defaultHasher.VerifyContext(ctx, password, hash)
+}
+
+func NeedsRehash(hash string) bool {
+	return defaultHasher.NeedsRehash(hash)
+}
+
+func VerifyAndUpgrade(password string, hash string) (string, error) {
+	return defaultHasher.VerifyAndUpgrade(password, hash)
 }
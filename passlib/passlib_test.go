@@ -3,6 +3,7 @@ package passlib_test
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/weplanx/go/passlib"
+	"strings"
 	"testing"
 )
 
@@ -21,6 +22,7 @@ const PASS3 = `$argon2id$v=18$m=65536,t=4,p=1$NPCjKIcoU2z6rg6p8glOfg$jrbRcvsTq/I
 const PASS4 = `$argon2id$v=19$xcxcsdsdwe$NPCjKIcoU2z6rg6p8glOfg$jrbRcvsTq/ITJP414/xhNNwOtVeHYa478hPn8M6uJLA`
 const PASS5 = `$argon2id$v=19$m=65536,t=4,p=1$()$jrbRcvsTq/ITJP414/xhNNwOtVeHYa478hPn8M6uJLA`
 const PASS6 = `$argon2id$v=19$m=65536,t=4,p=1$NPCjKIcoU2z6rg6p8glOfg$()`
+const PASS7 = `$argon2id$v=19$m=65536,t=0,p=1$NPCjKIcoU2z6rg6p8glOfg$jrbRcvsTq/ITJP414/xhNNwOtVeHYa478hPn8M6uJLA`
 
 func TestVerifyErrors(t *testing.T) {
 	var err error
@@ -39,4 +41,218 @@ func TestVerifyErrors(t *testing.T) {
 	err = passlib.Verify("pass@VAN1234", PASS6)
 	assert.Error(t, err)
 	t.Log(err)
+	err = passlib.Verify("pass@VAN1234", PASS7)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+}
+
+func TestIdentify(t *testing.T) {
+	id, err := passlib.Identify(PASS1)
+	assert.NoError(t, err)
+	assert.Equal(t, "argon2i", id)
+	id, err = passlib.Identify(`$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`)
+	assert.NoError(t, err)
+	assert.Equal(t, "2b", id)
+	_, err = passlib.Identify("argon2id$v=19")
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	_, err = passlib.Identify("$argon2id")
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	_, err = passlib.Identify("$$v=19")
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+}
+
+type plain struct{}
+
+func (x plain) Hash(password string) (string, error) {
+	return "$plain$" + password, nil
+}
+
+func (x plain) Verify(password string, hash string) error {
+	if hash != "$plain$"+password {
+		return passlib.ErrNotMatch
+	}
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	err := passlib.Verify("pass@VAN1234", "$plain$pass@VAN1234")
+	assert.ErrorIs(t, err, passlib.ErrIncompatibleVariant)
+
+	passlib.Register("plain", plain{})
+	algorithm, ok := passlib.Lookup("plain")
+	assert.True(t, ok)
+	assert.Equal(t, plain{}, algorithm)
+	assert.NoError(t, passlib.Verify("pass@VAN1234", "$plain$pass@VAN1234"))
+
+	passlib.DefaultAlgorithm = "plain"
+	defer func() {
+		passlib.DefaultAlgorithm = "argon2id"
+	}()
+	hash, err := passlib.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Equal(t, "$plain$pass@VAN1234", hash)
+
+	passlib.DefaultAlgorithm = "unknown"
+	_, err = passlib.Hash("pass@VAN1234")
+	assert.ErrorIs(t, err, passlib.ErrIncompatibleVariant)
+}
+
+type fixedArgon2id struct{}
+
+func (x fixedArgon2id) Hash(password string) (string, error) {
+	return "$argon2id$fixed$" + password, nil
+}
+
+func (x fixedArgon2id) Verify(password string, hash string) error {
+	if hash != "$argon2id$fixed$"+password {
+		return passlib.ErrNotMatch
+	}
+	return nil
+}
+
+func TestRegisterArgon2id(t *testing.T) {
+	builtin, ok := passlib.Lookup("argon2id")
+	assert.True(t, ok)
+	passlib.Register("argon2id", fixedArgon2id{})
+	defer passlib.Register("argon2id", builtin)
+
+	// A replaced argon2id is used by hashers too.
+	x := passlib.New()
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Equal(t, "$argon2id$fixed$pass@VAN1234", hash)
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, x.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+}
+
+func TestPreferred(t *testing.T) {
+	for _, id := range []string{"2a", "scrypt", "pbkdf2-sha256"} {
+		passlib.DefaultAlgorithm = id
+		hash, err := passlib.Hash("pass@VAN1234")
+		assert.NoError(t, err)
+		prefix, err := passlib.Identify(hash)
+		assert.NoError(t, err)
+		assert.Equal(t, id, prefix)
+		assert.NoError(t, passlib.Verify("pass@VAN1234", hash))
+		assert.ErrorIs(t, passlib.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	}
+	passlib.DefaultAlgorithm = "argon2id"
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := passlib.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.False(t, passlib.NeedsRehash(hash))
+
+	passlib.DefaultMemoryCost = 131072
+	assert.True(t, passlib.NeedsRehash(hash))
+	passlib.DefaultMemoryCost = 65536
+	passlib.DefaultTimeCost = 5
+	assert.True(t, passlib.NeedsRehash(hash))
+	passlib.DefaultTimeCost = 4
+	// Lowering the policy does not downgrade existing hashes.
+	passlib.DefaultTimeCost = 3
+	assert.False(t, passlib.NeedsRehash(hash))
+	passlib.DefaultTimeCost = 4
+
+	assert.True(t, passlib.NeedsRehash(PASS1))
+	assert.True(t, passlib.NeedsRehash(PASS3))
+	assert.True(t, passlib.NeedsRehash(PASS4))
+	assert.True(t, passlib.NeedsRehash("asdaqweqwexcxzcqweqw"))
+	assert.True(t, passlib.NeedsRehash(`$2b$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`))
+
+	passlib.DefaultAlgorithm = "2a"
+	passlib.DefaultBcryptCost = 5
+	defer func() {
+		passlib.DefaultAlgorithm = "argon2id"
+		passlib.DefaultBcryptCost = 12
+	}()
+	assert.False(t, passlib.NeedsRehash(`$2y$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`))
+	assert.True(t, passlib.NeedsRehash(`$2y$04$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW`))
+	assert.True(t, passlib.NeedsRehash(hash))
+}
+
+func TestVerifyAndUpgrade(t *testing.T) {
+	hash := `$pbkdf2-sha256$29000$d2VwbGFueC1wYXNzbGliIQ$cw9PFktAblTjP0UQ2ot8og8vQhQOjYiBONvFXnl15OQ`
+	_, err := passlib.VerifyAndUpgrade("Password", hash)
+	assert.ErrorIs(t, err, passlib.ErrNotMatch)
+
+	newHash, err := passlib.VerifyAndUpgrade("password", hash)
+	assert.NoError(t, err)
+	id, err := passlib.Identify(newHash)
+	assert.NoError(t, err)
+	assert.Equal(t, "argon2id", id)
+	assert.NoError(t, passlib.Verify("password", newHash))
+
+	newHash, err = passlib.VerifyAndUpgrade("password", newHash)
+	assert.NoError(t, err)
+	assert.Empty(t, newHash)
+}
+
+func TestHasher(t *testing.T) {
+	x := passlib.New(
+		passlib.SetMemory(8192),
+		passlib.SetTime(2),
+		passlib.SetThreads(2),
+		passlib.SetSaltLength(24),
+		passlib.SetKeyLength(48),
+	)
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=2,p=2$"))
+	parts := strings.Split(hash, "$")
+	assert.Len(t, parts[4], 32)
+	assert.Len(t, parts[5], 64)
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, x.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	assert.False(t, x.NeedsRehash(hash))
+
+	// Package level defaults are not affected and verify any hash.
+	assert.NoError(t, passlib.Verify("pass@VAN1234", hash))
+	assert.True(t, passlib.NeedsRehash(hash))
+
+	// Changing the globals afterwards does not change the instance.
+	passlib.DefaultMemoryCost = 131072
+	assert.False(t, x.NeedsRehash(hash))
+	passlib.DefaultMemoryCost = 65536
+
+	stronger := passlib.New(passlib.SetMemory(8192), passlib.SetTime(2), passlib.SetKeyLength(64))
+	assert.True(t, stronger.NeedsRehash(hash))
+	newHash, err := stronger.VerifyAndUpgrade("pass@VAN1234", hash)
+	assert.NoError(t, err)
+	assert.False(t, stronger.NeedsRehash(newHash))
+}
+
+func TestHasherAlgorithm(t *testing.T) {
+	x := passlib.New(passlib.SetAlgorithm("scrypt"))
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$scrypt$"))
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.False(t, x.NeedsRehash(hash))
+
+	argon2, err := passlib.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.True(t, x.NeedsRehash(argon2))
+
+	_, err = passlib.New(passlib.SetAlgorithm("unknown")).Hash("pass@VAN1234")
+	assert.ErrorIs(t, err, passlib.ErrIncompatibleVariant)
+}
+
+func TestHasherPepper(t *testing.T) {
+	x := passlib.New(passlib.SetPepper("1", []byte("6ixSiEXaqxsJTozbfoZBCGnDKPnaTDCc")))
+	old, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Contains(t, old, ",keyid=1$")
+	assert.ErrorIs(t, passlib.Verify("pass@VAN1234", old), passlib.ErrPepperNotExists)
+
+	rotated := passlib.New(
+		passlib.SetPepper("1", []byte("6ixSiEXaqxsJTozbfoZBCGnDKPnaTDCc")),
+		passlib.SetPepper("2", []byte("uBmKSdwsqMCDWUNuahfxaoNJPQvPDsVQ")),
+	)
+	assert.Equal(t, "2", rotated.Pepper)
+	assert.NoError(t, rotated.Verify("pass@VAN1234", old))
+	hash, err := rotated.VerifyAndUpgrade("pass@VAN1234", old)
+	assert.NoError(t, err)
+	assert.Contains(t, hash, ",keyid=2$")
+	assert.False(t, rotated.NeedsRehash(hash))
 }
@@ -0,0 +1,80 @@
+package passlib
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/pbkdf2"
+	"strconv"
+	"strings"
+)
+
+type Pbkdf2 struct {
+	Rounds int
+}
+
+func (x *Pbkdf2) rounds() int {
+	if x.Rounds == 0 {
+		return DefaultPbkdf2Rounds
+	}
+	return x.Rounds
+}
+
+func (x *Pbkdf2) Hash(password string) (hash string, err error) {
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	rounds := x.rounds()
+	key := pbkdf2.Key([]byte(password), salt, rounds, 32, sha256.New)
+
+	// Same layout as python passlib, which swaps "+" for ".".
+	encode := func(b []byte) string {
+		return strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(b), "+", ".")
+	}
+	return fmt.Sprintf(`$pbkdf2-sha256$%d$%s$%s`, rounds, encode(salt), encode(key)), nil
+}
+
+func (x *Pbkdf2) Verify(password string, hash string) (err error) {
+	options := strings.Split(hash, "$")
+	if len(options) != 5 {
+		return ErrInvalidHash
+	}
+	if options[1] != "pbkdf2-sha256" {
+		return ErrIncompatibleVariant
+	}
+	var rounds int
+	if rounds, err = strconv.Atoi(options[2]); err != nil || rounds <= 0 {
+		return ErrInvalidHash
+	}
+	var salt []byte
+	if salt, err = decodeBase64(options[3]); err != nil {
+		return
+	}
+	var key []byte
+	if key, err = decodeBase64(options[4]); err != nil {
+		return
+	}
+	if len(key) == 0 {
+		return ErrInvalidHash
+	}
+	otherKey := pbkdf2.Key([]byte(password), salt, rounds, len(key), sha256.New)
+	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
+		return
+	}
+	return ErrNotMatch
+}
+
+func (x *Pbkdf2) NeedsRehash(hash string) bool {
+	options := strings.Split(hash, "$")
+	if len(options) != 5 || options[1] != "pbkdf2-sha256" {
+		return true
+	}
+	rounds, err := strconv.Atoi(options[2])
+	if err != nil {
+		return true
+	}
+	return rounds < x.rounds()
+}
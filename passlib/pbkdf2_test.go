@@ -0,0 +1,33 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"testing"
+)
+
+func TestPbkdf2(t *testing.T) {
+	x := &passlib.Pbkdf2{Rounds: 1000}
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$pbkdf2-sha256$1000$")
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, x.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	assert.False(t, x.NeedsRehash(hash))
+	assert.True(t, (&passlib.Pbkdf2{Rounds: 2000}).NeedsRehash(hash))
+	assert.True(t, x.NeedsRehash("$pbkdf2-sha256$x$$"))
+}
+
+func TestPbkdf2Verify(t *testing.T) {
+	hash := `$pbkdf2-sha256$29000$d2VwbGFueC1wYXNzbGliIQ$cw9PFktAblTjP0UQ2ot8og8vQhQOjYiBONvFXnl15OQ`
+	assert.NoError(t, passlib.Verify("password", hash))
+	assert.ErrorIs(t, passlib.Verify("Password", hash), passlib.ErrNotMatch)
+
+	var err error
+	err = passlib.Verify("password", `$pbkdf2-sha256$x$d2VwbGFueC1wYXNzbGliIQ$cw9PFktAblTjP0UQ2ot8og8vQhQOjYiBONvFXnl15OQ`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	err = passlib.Verify("password", `$pbkdf2-sha256$29000$d2VwbGFueC1wYXNzbGliIQ`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	err = passlib.Verify("password", `$pbkdf2-sha256$29000$d2VwbGFueC1wYXNzbGliIQ$`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+}
@@ -0,0 +1,19 @@
+package passlib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+func pepper(peppers map[string][]byte, password string, id string) ([]byte, error) {
+	if id == "" {
+		return []byte(password), nil
+	}
+	secret, ok := peppers[id]
+	if !ok {
+		return nil, ErrPepperNotExists
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(password))
+	return mac.Sum(nil), nil
+}
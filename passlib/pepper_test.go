@@ -0,0 +1,65 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"strings"
+	"testing"
+)
+
+func TestPepper(t *testing.T) {
+	passlib.Peppers["1"] = []byte("6ixSiEXaqxsJTozbfoZBCGnDKPnaTDCc")
+	passlib.DefaultPepper = "1"
+	defer func() {
+		passlib.DefaultPepper = ""
+		delete(passlib.Peppers, "1")
+	}()
+
+	hash, err := passlib.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=4,p=1,keyid=1$"))
+	assert.NoError(t, passlib.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, passlib.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	assert.False(t, passlib.NeedsRehash(hash))
+
+	// Without the pepper the stored hash is useless.
+	secret := passlib.Peppers["1"]
+	passlib.Peppers["1"] = []byte("uBmKSdwsqMCDWUNuahfxaoNJPQvPDsVQ")
+	assert.ErrorIs(t, passlib.Verify("pass@VAN1234", hash), passlib.ErrNotMatch)
+	passlib.Peppers["1"] = secret
+	forged := strings.Replace(hash, ",keyid=1", "", 1)
+	assert.ErrorIs(t, passlib.Verify("pass@VAN1234", forged), passlib.ErrNotMatch)
+}
+
+func TestPepperRotation(t *testing.T) {
+	passlib.Peppers["1"] = []byte("6ixSiEXaqxsJTozbfoZBCGnDKPnaTDCc")
+	passlib.Peppers["2"] = []byte("uBmKSdwsqMCDWUNuahfxaoNJPQvPDsVQ")
+	passlib.DefaultPepper = "1"
+	defer func() {
+		passlib.DefaultPepper = ""
+		delete(passlib.Peppers, "1")
+		delete(passlib.Peppers, "2")
+	}()
+	unpeppered := `$argon2id$v=19$m=65536,t=4,p=1$NPCjKIcoU2z6rg6p8glOfg$jrbRcvsTq/ITJP414/xhNNwOtVeHYa478hPn8M6uJLA`
+	assert.True(t, passlib.NeedsRehash(unpeppered))
+
+	old, err := passlib.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+
+	passlib.DefaultPepper = "2"
+	assert.NoError(t, passlib.Verify("pass@VAN1234", old))
+	assert.True(t, passlib.NeedsRehash(old))
+	hash, err := passlib.VerifyAndUpgrade("pass@VAN1234", old)
+	assert.NoError(t, err)
+	assert.Contains(t, hash, ",keyid=2$")
+	assert.False(t, passlib.NeedsRehash(hash))
+
+	// Once retired, hashes made with the old pepper can not be verified.
+	delete(passlib.Peppers, "1")
+	assert.ErrorIs(t, passlib.Verify("pass@VAN1234", old), passlib.ErrPepperNotExists)
+	assert.NoError(t, passlib.Verify("pass@VAN1234", hash))
+
+	passlib.DefaultPepper = "3"
+	_, err = passlib.Hash("pass@VAN1234")
+	assert.ErrorIs(t, err, passlib.ErrPepperNotExists)
+}
@@ -0,0 +1,71 @@
+package passlib
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordTooWeak  = errors.New("password is too weak")
+	ErrPasswordBanned   = errors.New("password contains a banned term")
+	ErrPasswordBreached = errors.New("password has appeared in a data breach")
+)
+
+type Policy struct {
+	MinLength int
+	MinScore  int
+	Banned    []string
+	Breached  Checker
+}
+
+var DefaultPolicy = &Policy{MinLength: 8, MinScore: 3}
+
+// Validate checks a password against the policy, terms are banned for this
+// password only, such as the user name or email.
+func (x *Policy) Validate(password string, terms ...string) error {
+	if utf8.RuneCountInString(password) < x.MinLength {
+		return ErrPasswordTooShort
+	}
+	terms = append(append([]string{}, x.Banned...), terms...)
+	lower := strings.ToLower(password)
+	for _, term := range terms {
+		for _, v := range bannedParts(term) {
+			if strings.Contains(lower, v) {
+				return ErrPasswordBanned
+			}
+		}
+	}
+	if Estimate(password, terms...).Score < x.MinScore {
+		return ErrPasswordTooWeak
+	}
+	if x.Breached != nil {
+		breached, err := x.Breached.Breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			return ErrPasswordBreached
+		}
+	}
+	return nil
+}
+
+// Full names and the local part of emails are also banned word by word.
+func bannedParts(term string) (parts []string) {
+	term = strings.ToLower(strings.TrimSpace(term))
+	if len(term) >= 3 {
+		parts = append(parts, term)
+	}
+	local, _, _ := strings.Cut(term, "@")
+	for _, v := range strings.FieldsFunc(local, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(v) >= 3 && v != term {
+			parts = append(parts, v)
+		}
+	}
+	return
+}
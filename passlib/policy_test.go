@@ -0,0 +1,43 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"os"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	x := passlib.DefaultPolicy
+	assert.NoError(t, x.Validate("xK9#mQ2$vL7!"))
+	assert.ErrorIs(t, x.Validate("xK9#mQ2"), passlib.ErrPasswordTooShort)
+	assert.ErrorIs(t, x.Validate("password123"), passlib.ErrPasswordTooWeak)
+	assert.ErrorIs(t, x.Validate("Qwerty123456"), passlib.ErrPasswordTooWeak)
+
+	assert.ErrorIs(t, x.Validate("xK9#Kain$vL7!", "kain"), passlib.ErrPasswordBanned)
+	assert.ErrorIs(t, x.Validate("xK9#mQ2$weplanx", "weplanx.kain@example.com"), passlib.ErrPasswordBanned)
+	assert.NoError(t, x.Validate("xK9#mQ2$vL7!example", "kain@example.com"))
+	assert.NoError(t, x.Validate("xK9#mQ2$vL7!", "", "ab"))
+}
+
+func TestPolicyCustom(t *testing.T) {
+	x := &passlib.Policy{MinLength: 4, MinScore: 0, Banned: []string{"weplanx"}}
+	assert.NoError(t, x.Validate("password"))
+	assert.ErrorIs(t, x.Validate("WePlanX2024"), passlib.ErrPasswordBanned)
+
+	x = &passlib.Policy{MinLength: 4, MinScore: 4}
+	assert.ErrorIs(t, x.Validate("Tr0ub4dor"), passlib.ErrPasswordTooWeak)
+	assert.NoError(t, x.Validate("correct horse battery staple"))
+}
+
+func TestPolicyBreached(t *testing.T) {
+	breached, err := passlib.NewFilter(100, 0.001)
+	assert.NoError(t, err)
+	breached.Add("correct horse battery staple")
+	x := &passlib.Policy{MinLength: 8, MinScore: 3, Breached: breached}
+	assert.NoError(t, x.Validate("xK9#mQ2$vL7!"))
+	assert.ErrorIs(t, x.Validate("correct horse battery staple"), passlib.ErrPasswordBreached)
+
+	x.Breached = passlib.NewCorpus(t.TempDir())
+	assert.ErrorIs(t, x.Validate("xK9#mQ2$vL7!"), os.ErrNotExist)
+}
@@ -0,0 +1,101 @@
+package passlib
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"strings"
+)
+
+type Scrypt struct {
+	Cost        int
+	BlockSize   int
+	Parallelism int
+}
+
+func (x *Scrypt) params() (cost int, r int, p int) {
+	cost, r, p = x.Cost, x.BlockSize, x.Parallelism
+	if cost == 0 {
+		cost = DefaultScryptCost
+	}
+	if r == 0 {
+		r = 8
+	}
+	if p == 0 {
+		p = 1
+	}
+	return
+}
+
+func (x *Scrypt) Hash(password string) (hash string, err error) {
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	cost, r, p := x.params()
+	var key []byte
+	if key, err = scrypt.Key([]byte(password), salt, 1<<cost, r, p, 32); err != nil {
+		return
+	}
+	return fmt.Sprintf(`$scrypt$ln=%d,r=%d,p=%d$%s$%s`,
+		cost, r, p,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (x *Scrypt) Verify(password string, hash string) (err error) {
+	options := strings.Split(hash, "$")
+	if len(options) != 5 {
+		return ErrInvalidHash
+	}
+	if options[1] != "scrypt" {
+		return ErrIncompatibleVariant
+	}
+	var cost, r, p int
+	if _, err = fmt.Sscanf(options[2], "ln=%d,r=%d,p=%d", &cost, &r, &p); err != nil {
+		return ErrInvalidHash
+	}
+	if cost <= 0 || cost >= 32 {
+		return ErrInvalidHash
+	}
+	var salt []byte
+	if salt, err = decodeBase64(options[3]); err != nil {
+		return
+	}
+	var key []byte
+	if key, err = decodeBase64(options[4]); err != nil {
+		return
+	}
+	if len(key) == 0 {
+		return ErrInvalidHash
+	}
+	var otherKey []byte
+	if otherKey, err = scrypt.Key([]byte(password), salt, 1<<cost, r, p, len(key)); err != nil {
+		return ErrInvalidHash
+	}
+	if subtle.ConstantTimeCompare(key, otherKey) == 1 {
+		return
+	}
+	return ErrNotMatch
+}
+
+func (x *Scrypt) NeedsRehash(hash string) bool {
+	options := strings.Split(hash, "$")
+	if len(options) != 5 || options[1] != "scrypt" {
+		return true
+	}
+	var cost, r, p int
+	if _, err := fmt.Sscanf(options[2], "ln=%d,r=%d,p=%d", &cost, &r, &p); err != nil {
+		return true
+	}
+	c, br, bp := x.params()
+	return cost < c || r < br || p < bp
+}
+
+// Hashes exported by python passlib may use "." in place of "+".
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.Strict().DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
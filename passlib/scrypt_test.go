@@ -0,0 +1,37 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"testing"
+)
+
+func TestScrypt(t *testing.T) {
+	x := &passlib.Scrypt{Cost: 10}
+	hash, err := x.Hash("pass@VAN1234")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$scrypt$ln=10,r=8,p=1$")
+	assert.NoError(t, x.Verify("pass@VAN1234", hash))
+	assert.ErrorIs(t, x.Verify("pass@VAN1235", hash), passlib.ErrNotMatch)
+	assert.False(t, x.NeedsRehash(hash))
+	assert.True(t, (&passlib.Scrypt{Cost: 11}).NeedsRehash(hash))
+	assert.True(t, (&passlib.Scrypt{Cost: 10, BlockSize: 16}).NeedsRehash(hash))
+	assert.True(t, x.NeedsRehash("$scrypt$ln=10"))
+}
+
+func TestScryptVerify(t *testing.T) {
+	// Generated by python passlib.
+	hash := `$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E`
+	assert.NoError(t, passlib.Verify("password", hash))
+	assert.ErrorIs(t, passlib.Verify("Password", hash), passlib.ErrNotMatch)
+
+	var err error
+	err = passlib.Verify("password", `$scrypt$ln=16,r=8$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	err = passlib.Verify("password", `$scrypt$ln=64,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	err = passlib.Verify("password", `$scrypt$ln=16,r=8,p=1$aM15713r3Xsvxbi31lqr1Q$`)
+	assert.ErrorIs(t, err, passlib.ErrInvalidHash)
+	err = passlib.Verify("password", `$scrypt$ln=16,r=8,p=1$()$nFNh2CVHVjNldFVKDHDlm4CbdRSCdEBsjjJxD+iCs5E`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,370 @@
+package passlib
+
+import (
+	_ "embed"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed words.txt
+var wordList string
+
+var (
+	rankedOnce sync.Once
+	ranked     map[string]int
+	rankedMax  int
+)
+
+// Words are ranked by their line in words.txt, most common first.
+func rankedWords() map[string]int {
+	rankedOnce.Do(func() {
+		ranked = make(map[string]int)
+		for i, v := range strings.Fields(wordList) {
+			ranked[v] = i + 1
+			rankedMax = max(rankedMax, utf8.RuneCountInString(v))
+		}
+	})
+	return ranked
+}
+
+const maxEstimateLength = 256
+
+type Match struct {
+	Pattern string
+	Token   string
+	I       int
+	J       int
+	Guesses float64
+}
+
+type Strength struct {
+	Guesses float64
+	Score   int
+	Matches []Match
+}
+
+// Estimate scores a password from 0 to 4 by the fewest guesses an attacker
+// needs, combining dictionary, keyboard, repeat, sequence and year patterns.
+func Estimate(password string, userInputs ...string) *Strength {
+	runes := []rune(password)
+	if len(runes) > maxEstimateLength {
+		runes = runes[:maxEstimateLength]
+	}
+	inputs := make(map[string]int, len(userInputs))
+	for i, v := range userInputs {
+		if v = strings.ToLower(v); len(v) >= 3 {
+			inputs[v] = i + 1
+		}
+	}
+	matches := findMatches(runes, inputs)
+
+	// best[k] is the fewest guesses for the first k characters.
+	n := len(runes)
+	best := make([]float64, n+1)
+	from := make([]*Match, n+1)
+	best[0] = 1
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] * 10
+		for i := range matches {
+			m := &matches[i]
+			if m.J != j-1 {
+				continue
+			}
+			if v := best[m.I] * m.Guesses; v < best[j] {
+				best[j], from[j] = v, m
+			}
+		}
+	}
+
+	x := &Strength{Guesses: best[n]}
+	for j := n; j > 0; {
+		if m := from[j]; m != nil {
+			x.Matches = append([]Match{*m}, x.Matches...)
+			j = m.I
+			continue
+		}
+		// Consecutive unmatched characters form one bruteforce match.
+		i := j - 1
+		for i > 0 && from[i] == nil {
+			i--
+		}
+		x.Matches = append([]Match{{
+			Pattern: "bruteforce",
+			Token:   string(runes[i:j]),
+			I:       i,
+			J:       j - 1,
+			Guesses: math.Pow(10, float64(j-i)),
+		}}, x.Matches...)
+		j = i
+	}
+	switch {
+	case x.Guesses < 1e3:
+		x.Score = 0
+	case x.Guesses < 1e6:
+		x.Score = 1
+	case x.Guesses < 1e8:
+		x.Score = 2
+	case x.Guesses < 1e10:
+		x.Score = 3
+	default:
+		x.Score = 4
+	}
+	return x
+}
+
+func findMatches(runes []rune, inputs map[string]int) (matches []Match) {
+	add := func(pattern string, i int, j int, guesses float64) {
+		matches = append(matches, Match{
+			Pattern: pattern,
+			Token:   string(runes[i : j+1]),
+			I:       i,
+			J:       j,
+			Guesses: max(guesses, 10),
+		})
+	}
+	dictionaryMatches(runes, inputs, add)
+	spatialMatches(runes, add)
+	repeatMatches(runes, add)
+	sequenceMatches(runes, add)
+	yearMatches(runes, add)
+	return
+}
+
+type addMatch func(pattern string, i int, j int, guesses float64)
+
+var leet = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+func dictionaryMatches(runes []rune, inputs map[string]int, add addMatch) {
+	words := rankedWords()
+	// No token longer than the longest word or input can match.
+	longest := rankedMax
+	for v := range inputs {
+		longest = max(longest, utf8.RuneCountInString(v))
+	}
+	rank := func(token string) int {
+		if r, ok := inputs[token]; ok {
+			return r
+		}
+		return words[token]
+	}
+	n := len(runes)
+	lower := make([]rune, n)
+	unleet := make([]rune, n)
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		unleet[i] = lower[i]
+		if v, ok := leet[lower[i]]; ok {
+			unleet[i] = v
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 2; j < min(n, i+longest); j++ {
+			token := string(lower[i : j+1])
+			variations := uppercaseVariations(runes[i : j+1])
+			if r := rank(token); r != 0 {
+				add("dictionary", i, j, float64(r)*variations)
+			}
+			if reversed := reverse(token); reversed != token {
+				if r := rank(reversed); r != 0 {
+					add("dictionary", i, j, float64(r)*variations*2)
+				}
+			}
+			if substituted := string(unleet[i : j+1]); substituted != token {
+				if r := rank(substituted); r != 0 {
+					subs := 0
+					for k := i; k <= j; k++ {
+						if unleet[k] != lower[k] {
+							subs++
+						}
+					}
+					add("dictionary", i, j, float64(r)*variations*math.Pow(2, float64(subs)))
+				}
+			}
+		}
+	}
+}
+
+func uppercaseVariations(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+	// Capitalised or all caps words are the first variations tried.
+	if upper == 0 {
+		return 1
+	}
+	if upper == len(runes) || (upper == 1 && unicode.IsUpper(runes[0])) {
+		return 2
+	}
+	return math.Pow(2, float64(upper))
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+type keyPosition struct {
+	row int
+	col int
+}
+
+var keyboard = func() map[rune]keyPosition {
+	rows := []string{"1234567890-=", "qwertyuiop[]", "asdfghjkl;'", "zxcvbnm,./"}
+	shifted := []string{"!@#$%^&*()_+", "QWERTYUIOP{}", "ASDFGHJKL:\"", "ZXCVBNM<>?"}
+	keys := make(map[rune]keyPosition)
+	for r := range rows {
+		for c, v := range rows[r] {
+			keys[v] = keyPosition{r, c}
+		}
+		for c, v := range shifted[r] {
+			keys[v] = keyPosition{r, c}
+		}
+	}
+	return keys
+}()
+
+// Every row is shifted half a key to the right of the one above it.
+func adjacent(a keyPosition, b keyPosition) (int, bool) {
+	directions := []keyPosition{{-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}}
+	for i, d := range directions {
+		if a.row+d.row == b.row && a.col+d.col == b.col {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func spatialMatches(runes []rune, add addMatch) {
+	n := len(runes)
+	for i := 0; i < n-2; {
+		j, turns, direction := i, 0, -1
+		for j+1 < n {
+			a, ok := keyboard[runes[j]]
+			b, ok2 := keyboard[runes[j+1]]
+			if !ok || !ok2 {
+				break
+			}
+			d, ok := adjacent(a, b)
+			if !ok {
+				break
+			}
+			if direction != -1 && d != direction {
+				turns++
+			}
+			direction = d
+			j++
+		}
+		if j-i >= 2 {
+			length := float64(j - i + 1)
+			add("spatial", i, j, 40*length*math.Pow(4, float64(turns))*uppercaseVariations(runes[i:j+1]))
+			i = j
+			continue
+		}
+		i++
+	}
+}
+
+func repeatMatches(runes []rune, add addMatch) {
+	words := rankedWords()
+	n := len(runes)
+	for i := 0; i < n; i++ {
+		for size := 1; i+size*2 <= n; size++ {
+			// Only the start of a run is reported, later offsets are covered by it.
+			if i > 0 && runes[i-1] == runes[i-1+size] {
+				continue
+			}
+			unit := runes[i : i+size]
+			count := 1
+			for i+size*(count+1) <= n && slices.Equal(runes[i+size*count:i+size*(count+1)], unit) {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			// The repeated unit is guessed as a word or by bruteforce.
+			base := math.Pow(10, float64(size))
+			if size <= rankedMax {
+				if r := words[strings.ToLower(string(unit))]; r != 0 {
+					base = min(base, float64(r)*uppercaseVariations(unit))
+				}
+			}
+			add("repeat", i, i+size*count-1, base*float64(count))
+		}
+	}
+}
+
+func sequenceMatches(runes []rune, add addMatch) {
+	class := func(r rune) int {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 1
+		case r >= 'A' && r <= 'Z':
+			return 2
+		case r >= '0' && r <= '9':
+			return 3
+		}
+		return 0
+	}
+	n := len(runes)
+	for i := 0; i < n-2; {
+		delta := runes[i+1] - runes[i]
+		c := class(runes[i])
+		if c == 0 || class(runes[i+1]) != c || (delta != 1 && delta != -1) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j+1 < n && runes[j+1]-runes[j] == delta && class(runes[j+1]) == c {
+			j++
+		}
+		if j-i < 2 {
+			i++
+			continue
+		}
+		var base float64
+		switch {
+		case strings.ContainsRune("aAzZ019", runes[i]):
+			base = 4
+		case c == 3:
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		add("sequence", i, j, base*float64(j-i+1))
+		i = j
+	}
+}
+
+func yearMatches(runes []rune, add addMatch) {
+	now := time.Now().Year()
+	for i := 0; i+4 <= len(runes); i++ {
+		year := 0
+		for _, r := range runes[i : i+4] {
+			if r < '0' || r > '9' {
+				year = -1
+				break
+			}
+			year = year*10 + int(r-'0')
+		}
+		if year >= 1900 && year <= now+20 {
+			add("year", i, i+3, float64(max(now-year, year-now, 20)))
+		}
+	}
+}
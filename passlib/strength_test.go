@@ -0,0 +1,74 @@
+package passlib_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/passlib"
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	for password, score := range map[string]int{
+		"":                             0,
+		"password":                     0,
+		"P@ssw0rd":                     0,
+		"password123":                  0,
+		"iloveyou2024":                 0,
+		"xK9#mQ2$vL7!":                 4,
+		"correct horse battery staple": 4,
+	} {
+		assert.Equal(t, score, passlib.Estimate(password).Score, password)
+	}
+}
+
+func TestEstimatePatterns(t *testing.T) {
+	for password, pattern := range map[string]string{
+		"qwerty":   "dictionary",
+		"drowssap": "dictionary",
+		"PASSWORD": "dictionary",
+		"p4ssw0rd": "dictionary",
+		"wsxcde":   "spatial",
+		"ZXCVFR":   "spatial",
+		"kkkkkkkk": "repeat",
+		"xyzpxyzp": "repeat",
+		"日本日本日本":   "repeat",
+		"mnopqrs":  "sequence",
+		"76543":    "sequence",
+		"1987":     "year",
+	} {
+		x := passlib.Estimate(password)
+		assert.Len(t, x.Matches, 1, password)
+		assert.Equal(t, pattern, x.Matches[0].Pattern, password)
+		assert.Equal(t, password, x.Matches[0].Token)
+		assert.Less(t, x.Score, 2, password)
+	}
+}
+
+func TestEstimateMatches(t *testing.T) {
+	x := passlib.Estimate("Dragon#qA7z")
+	assert.Equal(t, "dictionary", x.Matches[0].Pattern)
+	assert.Equal(t, "Dragon", x.Matches[0].Token)
+	tokens := make([]string, len(x.Matches))
+	for i, v := range x.Matches {
+		tokens[i] = v.Token
+	}
+	assert.Equal(t, "Dragon#qA7z", strings.Join(tokens, ""))
+
+	// User inputs are as guessable as the most common words.
+	assert.Equal(t, 4, passlib.Estimate("vanwinkleberg").Score)
+	assert.Equal(t, 0, passlib.Estimate("vanwinkleberg", "VanWinkleBerg").Score)
+
+	long := strings.Repeat("ab", 1000)
+	assert.Equal(t, 1, passlib.Estimate(long).Score)
+}
+
+func BenchmarkEstimateLong(b *testing.B) {
+	passwords := []string{
+		strings.Repeat("a", 256),
+		strings.Repeat("xK9#mQ2$vL7!", 22),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		passlib.Estimate(passwords[i%len(passwords)], "alice@example.com")
+	}
+}
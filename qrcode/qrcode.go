@@ -0,0 +1,518 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+type Level int
+
+const (
+	L Level = iota
+	M
+	Q
+	H
+)
+
+var (
+	ErrTooLong      = errors.New("content is too long to be encoded")
+	ErrInvalidLevel = errors.New("error correction level is not supported")
+)
+
+type QRCode struct {
+	Version int
+	Level   Level
+	Size    int
+	Modules [][]bool
+
+	function [][]bool
+}
+
+func New(content string, level Level) (x *QRCode, err error) {
+	if level < L || level > H {
+		return nil, ErrInvalidLevel
+	}
+	data := []byte(content)
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if 4+countBits(v)+len(data)*8 <= dataCodewords(v, level)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	x = &QRCode{
+		Version: version,
+		Level:   level,
+		Size:    version*4 + 17,
+	}
+	x.Modules = make([][]bool, x.Size)
+	x.function = make([][]bool, x.Size)
+	for i := range x.Modules {
+		x.Modules[i] = make([]bool, x.Size)
+		x.function[i] = make([]bool, x.Size)
+	}
+
+	x.drawFunctionPatterns()
+	x.drawCodewords(x.addEcc(x.encode(data)))
+
+	mask, penalty := 0, -1
+	for i := 0; i < 8; i++ {
+		x.applyMask(i)
+		x.drawFormatBits(i)
+		if p := x.penalty(); penalty < 0 || p < penalty {
+			mask, penalty = i, p
+		}
+		x.applyMask(i)
+	}
+	x.applyMask(mask)
+	x.drawFormatBits(mask)
+	x.function = nil
+	return
+}
+
+func (x *QRCode) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	border := 4
+	n := (x.Size + border*2) * scale
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for y := 0; y < x.Size; y++ {
+		for x0 := 0; x0 < x.Size; x0++ {
+			if !x.Modules[y][x0] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x0+border)*scale+dx, (y+border)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+func (x *QRCode) PNG(scale int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, x.Image(scale)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (x *QRCode) SVG(scale int) string {
+	if scale < 1 {
+		scale = 1
+	}
+	border := 4
+	n := x.Size + border*2
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`,
+		n, n, n*scale, n*scale)
+	b.WriteString(`<rect width="100%" height="100%" fill="#FFFFFF"/><path d="`)
+	for y := 0; y < x.Size; y++ {
+		for x0 := 0; x0 < x.Size; x0++ {
+			if x.Modules[y][x0] {
+				fmt.Fprintf(&b, "M%d,%dh1v1h-1z", x0+border, y+border)
+			}
+		}
+	}
+	b.WriteString(`" fill="#000000"/></svg>`)
+	return b.String()
+}
+
+func (x *QRCode) set(col int, row int, dark bool) {
+	x.Modules[row][col] = dark
+	x.function[row][col] = true
+}
+
+func (x *QRCode) drawFunctionPatterns() {
+	for i := 0; i < x.Size; i++ {
+		x.set(6, i, i%2 == 0)
+		x.set(i, 6, i%2 == 0)
+	}
+
+	x.drawFinder(3, 3)
+	x.drawFinder(x.Size-4, 3)
+	x.drawFinder(3, x.Size-4)
+
+	positions := alignmentPositions(x.Version)
+	n := len(positions)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue
+			}
+			x.drawAlignment(positions[i], positions[j])
+		}
+	}
+
+	// Reserve the format areas, the real bits are drawn once the mask is known.
+	x.drawFormatBits(0)
+	x.drawVersion()
+}
+
+func (x *QRCode) drawFinder(col int, row int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			c, r := col+dx, row+dy
+			if c < 0 || c >= x.Size || r < 0 || r >= x.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			x.set(c, r, d != 2 && d != 4)
+		}
+	}
+}
+
+func (x *QRCode) drawAlignment(col int, row int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			x.set(col+dx, row+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (x *QRCode) drawFormatBits(mask int) {
+	data := formatLevels[x.Level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		x.set(8, i, bit(bits, i))
+	}
+	x.set(8, 7, bit(bits, 6))
+	x.set(8, 8, bit(bits, 7))
+	x.set(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		x.set(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		x.set(x.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		x.set(8, x.Size-15+i, bit(bits, i))
+	}
+	x.set(8, x.Size-8, true)
+}
+
+func (x *QRCode) drawVersion() {
+	if x.Version < 7 {
+		return
+	}
+	rem := x.Version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := x.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		a, b := x.Size-11+i%3, i/3
+		x.set(a, b, bit(bits, i))
+		x.set(b, a, bit(bits, i))
+	}
+}
+
+func (x *QRCode) encode(data []byte) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4)
+	bb.append(len(data), countBits(x.Version))
+	for _, v := range data {
+		bb.append(int(v), 8)
+	}
+	capacity := dataCodewords(x.Version, x.Level) * 8
+	bb.append(0, min(4, capacity-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xec; len(bb) < capacity; pad ^= 0xec ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	out := make([]byte, len(bb)/8)
+	for i, v := range bb {
+		if v {
+			out[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return out
+}
+
+func (x *QRCode) addEcc(data []byte) []byte {
+	numBlocks := eccBlocks[x.Level][x.Version]
+	eccLen := eccCodewords[x.Level][x.Version]
+	raw := rawModules(x.Version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		block := make([]byte, 0, shortLen+1)
+		block = append(block, data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(block, divisor)
+		if i < numShort {
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+
+	out := make([]byte, 0, raw)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				out = append(out, block[i])
+			}
+		}
+	}
+	return out
+}
+
+func (x *QRCode) drawCodewords(data []byte) {
+	i := 0
+	for right := x.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < x.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				col := right - j
+				row := vert
+				if (right+1)&2 == 0 {
+					row = x.Size - 1 - vert
+				}
+				if !x.function[row][col] && i < len(data)*8 {
+					x.Modules[row][col] = bit(int(data[i>>3]), 7-i&7)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (x *QRCode) applyMask(mask int) {
+	for row := 0; row < x.Size; row++ {
+		for col := 0; col < x.Size; col++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (col+row)%2 == 0
+			case 1:
+				invert = row%2 == 0
+			case 2:
+				invert = col%3 == 0
+			case 3:
+				invert = (col+row)%3 == 0
+			case 4:
+				invert = (col/3+row/2)%2 == 0
+			case 5:
+				invert = col*row%2+col*row%3 == 0
+			case 6:
+				invert = (col*row%2+col*row%3)%2 == 0
+			case 7:
+				invert = ((col+row)%2+col*row%3)%2 == 0
+			}
+			if invert && !x.function[row][col] {
+				x.Modules[row][col] = !x.Modules[row][col]
+			}
+		}
+	}
+}
+
+func (x *QRCode) penalty() (result int) {
+	get := func(row int, col int, transpose bool) bool {
+		if transpose {
+			return x.Modules[col][row]
+		}
+		return x.Modules[row][col]
+	}
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		for row := 0; row < x.Size; row++ {
+			run := 1
+			for col := 1; col <= x.Size; col++ {
+				if col < x.Size && get(row, col, transpose) == get(row, col-1, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+			for col := 0; col+7 <= x.Size; col++ {
+				match := true
+				for k, v := range finder {
+					if get(row, col+k, transpose) != v {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				if x.light(row, col-4, col, transpose) || x.light(row, col+7, col+11, transpose) {
+					result += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for row := 0; row < x.Size; row++ {
+		for col := 0; col < x.Size; col++ {
+			if x.Modules[row][col] {
+				dark++
+			}
+			if row+1 < x.Size && col+1 < x.Size {
+				v := x.Modules[row][col]
+				if v == x.Modules[row][col+1] && v == x.Modules[row+1][col] && v == x.Modules[row+1][col+1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := x.Size * x.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return
+}
+
+func (x *QRCode) light(row int, from int, to int, transpose bool) bool {
+	for col := from; col < to; col++ {
+		if col < 0 || col >= x.Size {
+			continue
+		}
+		if transpose && x.Modules[col][row] || !transpose && x.Modules[row][col] {
+			return false
+		}
+	}
+	return true
+}
+
+type bitBuffer []bool
+
+func (x *bitBuffer) append(v int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*x = append(*x, bit(v, i))
+	}
+}
+
+func bit(v int, i int) bool {
+	return (v>>i)&1 != 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+func rawModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		n := version/7 + 2
+		result -= (25*n-10)*n - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func dataCodewords(version int, level Level) int {
+	return rawModules(version)/8 - eccCodewords[level][version]*eccBlocks[level][version]
+}
+
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	n := version/7 + 2
+	step := (version*4 + n*2 + 1) / (n*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, v := range divisor {
+			result[i] ^= gfMultiply(v, factor)
+		}
+	}
+	return result
+}
+
+func gfMultiply(a byte, b byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= int((b>>i)&1) * int(a)
+	}
+	return byte(z)
+}
+
+var formatLevels = [4]int{1, 0, 3, 2}
+
+var eccCodewords = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var eccBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
@@ -0,0 +1,66 @@
+package qrcode_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/qrcode"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	x, err := qrcode.New("HELLO WORLD", qrcode.Q)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, x.Version)
+	assert.Equal(t, 21, x.Size)
+
+	// Finder patterns sit in three corners.
+	for _, v := range [][2]int{{0, 0}, {0, x.Size - 7}, {x.Size - 7, 0}} {
+		for i := 0; i < 7; i++ {
+			assert.True(t, x.Modules[v[0]][v[1]+i])
+			assert.True(t, x.Modules[v[0]+6][v[1]+i])
+			assert.True(t, x.Modules[v[0]+i][v[1]])
+			assert.True(t, x.Modules[v[0]+i][v[1]+6])
+		}
+		assert.False(t, x.Modules[v[0]+1][v[1]+1])
+		assert.True(t, x.Modules[v[0]+3][v[1]+3])
+	}
+	// Dark module.
+	assert.True(t, x.Modules[x.Size-8][8])
+
+	x, err = qrcode.New(strings.Repeat("a", 1000), qrcode.M)
+	assert.NoError(t, err)
+	assert.Equal(t, 26, x.Version)
+	assert.Equal(t, 121, x.Size)
+
+	x, err = qrcode.New(strings.Repeat("a", 2953), qrcode.L)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, x.Version)
+
+	_, err = qrcode.New(strings.Repeat("a", 2954), qrcode.L)
+	assert.ErrorIs(t, err, qrcode.ErrTooLong)
+	_, err = qrcode.New("a", qrcode.Level(4))
+	assert.ErrorIs(t, err, qrcode.ErrInvalidLevel)
+}
+
+func TestRender(t *testing.T) {
+	x, err := qrcode.New("otpauth://totp/alice?secret=2SH3V3GDW7ZNMGYE", qrcode.M)
+	assert.NoError(t, err)
+
+	b, err := x.PNG(2)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, (x.Size+8)*2, img.Bounds().Dx())
+	r, _, _, _ := img.At(8, 8).RGBA()
+	assert.Equal(t, uint32(0), r)
+	r, _, _, _ = img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+
+	svg := x.SVG(3)
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg"`))
+	assert.True(t, strings.HasSuffix(svg, `</svg>`))
+	assert.Contains(t, svg, fmt.Sprintf(`width="%d"`, (x.Size+8)*3))
+}
@@ -0,0 +1,113 @@
+package totp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"github.com/weplanx/go/cipher"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrEnrollmentNotExists = errors.New("the enrollment does not exists")
+)
+
+type Enrollment struct {
+	RDb    *redis.Client
+	Cipher *cipher.Cipher
+	Clock  Clock
+}
+
+func NewEnrollment(rdb *redis.Client, cipher *cipher.Cipher) *Enrollment {
+	return &Enrollment{RDb: rdb, Cipher: cipher}
+}
+
+func (x *Enrollment) Key(subject string) string {
+	return fmt.Sprintf(`totp:enrollment:%s`, subject)
+}
+
+func (x *Enrollment) Now() time.Time {
+	if x.Clock == nil {
+		return time.Now()
+	}
+	return x.Clock.Now()
+}
+
+func (x *Enrollment) Create(ctx context.Context, subject string, ttl time.Duration, options ...Option) (data *Totp, err error) {
+	if data, err = New(options...); err != nil {
+		return
+	}
+	data.Subject = subject
+	if err = x.save(ctx, data, ttl); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *Enrollment) save(ctx context.Context, data *Totp, ttl time.Duration) (err error) {
+	var b []byte
+	if b, err = json.Marshal(data); err != nil {
+		return
+	}
+	var ciphertext string
+	if ciphertext, err = x.Cipher.Encode(b); err != nil {
+		return
+	}
+	return x.RDb.Set(ctx, x.Key(data.Subject), ciphertext, ttl).Err()
+}
+
+func (x *Enrollment) Pending(ctx context.Context, subject string) (data *Totp, err error) {
+	var ciphertext string
+	if ciphertext, err = x.RDb.Get(ctx, x.Key(subject)).Result(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrEnrollmentNotExists
+		}
+		return
+	}
+	var b []byte
+	if b, err = x.Cipher.Decode(ciphertext); err != nil {
+		return
+	}
+	data = &Totp{Clock: x.Clock}
+	if err = json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *Enrollment) Confirm(ctx context.Context, subject string, password string) (*Totp, error) {
+	return x.ConfirmAt(ctx, subject, x.Now(), password)
+}
+
+func (x *Enrollment) ConfirmAt(ctx context.Context, subject string, t time.Time, password string) (data *Totp, err error) {
+	if data, err = x.Pending(ctx, subject); err != nil {
+		return
+	}
+	// Only a one-time code proves the authenticator was set up.
+	if len(password) != data.GetDigits() || password[0] < '0' || password[0] > '9' {
+		return nil, ErrNotMatch
+	}
+	var code int
+	if code, err = strconv.Atoi(password); err != nil {
+		return nil, ErrNotMatch
+	}
+	var ok bool
+	if ok, err = data.checkOtp(ctx, t, code); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotMatch
+	}
+	// Concurrent confirmations race on the delete, only one activates.
+	if x.Delete(ctx, subject) == 0 {
+		return nil, ErrEnrollmentNotExists
+	}
+	return
+}
+
+func (x *Enrollment) Delete(ctx context.Context, subject string) int64 {
+	return x.RDb.Del(ctx, x.Key(subject)).Val()
+}
@@ -0,0 +1,98 @@
+package totp_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/cipher"
+	"github.com/weplanx/go/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnrollment(t *testing.T) {
+	ctx := context.TODO()
+	c, err := cipher.New("6ixSiEXaqxsJTozbnxQ76CWdZXB2JazK")
+	assert.NoError(t, err)
+	x := totp.NewEnrollment(rdb, c)
+	x.Delete(ctx, "enroll")
+
+	_, err = x.Pending(ctx, "enroll")
+	assert.ErrorIs(t, err, totp.ErrEnrollmentNotExists)
+
+	data, err := x.Create(ctx, "enroll", time.Minute*10,
+		totp.SetIssuer("Example"),
+		totp.SetAccount("alice"),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "enroll", data.Subject)
+
+	stored := x.RDb.Get(ctx, x.Key("enroll")).Val()
+	assert.NotContains(t, stored, data.Secret)
+	assert.False(t, strings.Contains(stored, "alice"))
+
+	pending, err := x.Pending(ctx, "enroll")
+	assert.NoError(t, err)
+	assert.Equal(t, data.Secret, pending.Secret)
+	assert.Equal(t, "alice", pending.Account)
+
+	_, err = x.Confirm(ctx, "enroll", "abcdef")
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	code := fmt.Sprintf("%06d", data.Compute(int64(data.TimeStep(time.Now()))))
+	wrong := fmt.Sprintf("%06d", (data.Compute(int64(data.TimeStep(time.Now())))+500000)%1000000)
+	_, err = x.Confirm(ctx, "enroll", wrong)
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+
+	activated, err := x.Confirm(ctx, "enroll", code)
+	assert.NoError(t, err)
+	assert.Equal(t, data.Secret, activated.Secret)
+	assert.Equal(t, "Example", activated.Issuer)
+
+	_, err = x.Confirm(ctx, "enroll", code)
+	assert.ErrorIs(t, err, totp.ErrEnrollmentNotExists)
+}
+
+func TestEnrollmentClock(t *testing.T) {
+	ctx := context.TODO()
+	c, err := cipher.New("6ixSiEXaqxsJTozbnxQ76CWdZXB2JazK")
+	assert.NoError(t, err)
+	now := time.Unix(300000, 0)
+	x := totp.NewEnrollment(rdb, c)
+	x.Clock = totp.ClockFunc(func() time.Time { return now })
+	x.Delete(ctx, "enroll-clock")
+
+	data, err := x.Create(ctx, "enroll-clock", time.Minute*10)
+	assert.NoError(t, err)
+	pending, err := x.Pending(ctx, "enroll-clock")
+	assert.NoError(t, err)
+	assert.Equal(t, now, pending.Now())
+
+	// Codes are checked against the injected clock, not the wall clock.
+	code := fmt.Sprintf("%06d", data.Compute(int64(data.TimeStep(now))))
+	_, err = x.ConfirmAt(ctx, "enroll-clock", now.Add(time.Hour), code)
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	activated, err := x.Confirm(ctx, "enroll-clock", code)
+	assert.NoError(t, err)
+	assert.Equal(t, data.Secret, activated.Secret)
+}
+
+func TestEnrollmentExpired(t *testing.T) {
+	ctx := context.TODO()
+	c, err := cipher.New("6ixSiEXaqxsJTozbnxQ76CWdZXB2JazK")
+	assert.NoError(t, err)
+	x := totp.NewEnrollment(rdb, c)
+	_, err = x.Create(ctx, "abandon", time.Millisecond)
+	assert.NoError(t, err)
+	time.Sleep(time.Second)
+	_, err = x.Pending(ctx, "abandon")
+	assert.ErrorIs(t, err, totp.ErrEnrollmentNotExists)
+
+	other, err := cipher.New("74rILbVooYLirHrQJcslHEAvKZI7PKF9")
+	assert.NoError(t, err)
+	_, err = x.Create(ctx, "tampered", time.Minute)
+	assert.NoError(t, err)
+	_, err = totp.NewEnrollment(rdb, other).Pending(ctx, "tampered")
+	assert.Error(t, err)
+	assert.Equal(t, int64(1), x.Delete(ctx, "tampered"))
+}
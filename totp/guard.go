@@ -0,0 +1,39 @@
+package totp
+
+import (
+	"context"
+	"github.com/weplanx/go/locker"
+	"time"
+)
+
+type Limiter interface {
+	Update(ctx context.Context, name string, ttl time.Duration) int64
+	Delete(ctx context.Context, name string) int64
+}
+
+type Guard struct {
+	Limiter Limiter
+	Max     int64
+	TTL     time.Duration
+}
+
+func NewGuard(limiter Limiter, max int64, ttl time.Duration) *Guard {
+	return &Guard{Limiter: limiter, Max: max, TTL: ttl}
+}
+
+func (x *Guard) Name(subject string) string {
+	return "totp:" + subject
+}
+
+// Attempt counts an attempt before the code is checked, so concurrent
+// guesses can not all slip past a counter that has not been raised yet.
+func (x *Guard) Attempt(ctx context.Context, subject string) error {
+	if x.Limiter.Update(ctx, x.Name(subject), x.TTL) > x.Max {
+		return locker.ErrLocked
+	}
+	return nil
+}
+
+func (x *Guard) Reset(ctx context.Context, subject string) int64 {
+	return x.Limiter.Delete(ctx, x.Name(subject))
+}
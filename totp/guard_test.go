@@ -0,0 +1,86 @@
+package totp_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/locker"
+	"github.com/weplanx/go/totp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuard(t *testing.T) {
+	ctx := context.TODO()
+	guard := totp.NewGuard(locker.New(rdb), 3, time.Minute)
+	guard.Reset(ctx, "guard")
+	x := &totp.Totp{
+		Secret:  "2SH3V3GDW7ZNMGYE",
+		Window:  3,
+		Subject: "guard",
+		Guard:   guard,
+	}
+	now := time.Unix(300000, 0)
+	code := fmt.Sprintf("%06d", x.Compute(int64(x.TimeStep(now))))
+
+	r, err := x.AuthenticateAt(now, "abc")
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	assert.False(t, r)
+	r, err = x.AuthenticateAt(now, "000000")
+	assert.NoError(t, err)
+	assert.False(t, r)
+
+	// A success clears the failures.
+	r, err = x.AuthenticateAt(now, code)
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, int64(0), rdb.Exists(ctx, locker.New(rdb).Key(guard.Name("guard"))).Val())
+
+	for i := 0; i < 3; i++ {
+		r, err = x.AuthenticateAt(now, "000000")
+		assert.NoError(t, err)
+		assert.False(t, r)
+	}
+	// Once locked even the right code is refused.
+	r, err = x.AuthenticateAt(now, code)
+	assert.ErrorIs(t, err, locker.ErrLocked)
+	assert.False(t, r)
+
+	x.Subject = ""
+	_, err = x.AuthenticateAt(now, code)
+	assert.ErrorIs(t, err, totp.ErrMissingSubject)
+	assert.Equal(t, int64(1), guard.Reset(ctx, "guard"))
+}
+
+func TestGuardConcurrent(t *testing.T) {
+	x := &totp.Totp{
+		Secret:  "2SH3V3GDW7ZNMGYE",
+		Window:  3,
+		Subject: "guard",
+		Guard:   totp.NewGuard(&memoryLimiter{}, 3, time.Minute),
+	}
+	now := time.Unix(300000, 0)
+
+	// Parallel guesses are counted before they are checked.
+	var verified, locked atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := x.AuthenticateAt(now, "000000")
+			assert.False(t, r)
+			if err == nil {
+				verified.Add(1)
+			} else {
+				assert.ErrorIs(t, err, locker.ErrLocked)
+				locked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), verified.Load())
+	assert.Equal(t, int32(7), locked.Load())
+}
@@ -0,0 +1,55 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+var (
+	ErrInvalidAlgorithm = errors.New("algorithm is not supported")
+	ErrInvalidDigits    = errors.New("digits must be between 1 and 10")
+)
+
+// Key holds a decoded secret and a reusable HMAC, it is not safe for concurrent use.
+type Key struct {
+	modulus int64
+	mac     hash.Hash
+	buf     [8]byte
+	sum     []byte
+}
+
+func NewKey(secret string, algorithm Algorithm, digits int) (x *Key, err error) {
+	h := algorithm.Hash()
+	if h == nil {
+		return nil, ErrInvalidAlgorithm
+	}
+	if digits < 1 || digits > 10 {
+		return nil, ErrInvalidDigits
+	}
+	var key []byte
+	if key, err = DecodeSecret(secret); err != nil {
+		return
+	}
+	x = &Key{
+		modulus: 1,
+		mac:     hmac.New(h, key),
+	}
+	for i := 0; i < digits; i++ {
+		x.modulus *= 10
+	}
+	x.sum = make([]byte, 0, x.mac.Size())
+	return
+}
+
+func (x *Key) Compute(value int64) int {
+	binary.BigEndian.PutUint64(x.buf[:], uint64(value))
+	x.mac.Reset()
+	x.mac.Write(x.buf[:])
+	x.sum = x.mac.Sum(x.sum[:0])
+
+	offset := x.sum[len(x.sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(x.sum[offset:offset+4]) & 0x7fffffff
+	return int(int64(truncated) % x.modulus)
+}
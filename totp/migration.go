@@ -0,0 +1,280 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrInvalidMigration     = errors.New("invalid otpauth-migration payload")
+	ErrUnsupportedMigration = errors.New("parameters can not be represented in a migration payload")
+	ErrIncompleteMigration  = errors.New("migration batches are incomplete")
+)
+
+const DefaultMigrationBatchSize = 10
+
+type MigrationBatch struct {
+	Version int
+	Size    int
+	Index   int
+	Id      int32
+}
+
+var migrationAlgorithms = map[Algorithm]uint64{"": 1, SHA1: 1, SHA256: 2, SHA512: 3}
+
+func EncodeMigration(items []*Totp, batchSize int) (uris []string, err error) {
+	if batchSize <= 0 {
+		batchSize = DefaultMigrationBatchSize
+	}
+	var id [4]byte
+	if _, err = rand.Read(id[:]); err != nil {
+		return
+	}
+	batch := MigrationBatch{
+		Version: 1,
+		Size:    (len(items) + batchSize - 1) / batchSize,
+		Id:      int32(binary.BigEndian.Uint32(id[:]) & 0x7fffffff),
+	}
+	for ; batch.Index < batch.Size; batch.Index++ {
+		end := min((batch.Index+1)*batchSize, len(items))
+		var payload []byte
+		if payload, err = encodeMigrationPayload(items[batch.Index*batchSize:end], batch); err != nil {
+			return nil, err
+		}
+		uris = append(uris, "otpauth-migration://offline?data="+
+			url.QueryEscape(base64.StdEncoding.EncodeToString(payload)))
+	}
+	return
+}
+
+func encodeMigrationPayload(items []*Totp, batch MigrationBatch) (b []byte, err error) {
+	for _, v := range items {
+		var p []byte
+		if p, err = encodeMigrationParameters(v); err != nil {
+			return
+		}
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, p)
+	}
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(batch.Version))
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(batch.Size))
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(batch.Index))
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(batch.Id))
+	return
+}
+
+func encodeMigrationParameters(x *Totp) (b []byte, err error) {
+	var secret []byte
+	if secret, err = DecodeSecret(x.Secret); err != nil {
+		return
+	}
+	algorithm, ok := migrationAlgorithms[x.Algorithm]
+	if !ok {
+		return nil, ErrUnsupportedMigration
+	}
+	var digits uint64
+	switch x.GetDigits() {
+	case 6:
+		digits = 1
+	case 8:
+		digits = 2
+	default:
+		return nil, ErrUnsupportedMigration
+	}
+	// The payload has no period field, authenticators assume 30 seconds.
+	kind := uint64(2)
+	if x.IsHotp() {
+		kind = 1
+	} else if x.GetPeriod() != DefaultPeriod {
+		return nil, ErrUnsupportedMigration
+	}
+
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, secret)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendString(b, x.Account)
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendString(b, x.Issuer)
+	b = protowire.AppendTag(b, 4, protowire.VarintType)
+	b = protowire.AppendVarint(b, algorithm)
+	b = protowire.AppendTag(b, 5, protowire.VarintType)
+	b = protowire.AppendVarint(b, digits)
+	b = protowire.AppendTag(b, 6, protowire.VarintType)
+	b = protowire.AppendVarint(b, kind)
+	if kind == 1 {
+		b = protowire.AppendTag(b, 7, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(x.Counter))
+	}
+	return
+}
+
+func DecodeMigration(uri string) (items []*Totp, batch MigrationBatch, err error) {
+	var u *url.URL
+	if u, err = url.Parse(uri); err != nil {
+		return nil, batch, ErrInvalidMigration
+	}
+	if u.Scheme != "otpauth-migration" || u.Host != "offline" {
+		return nil, batch, ErrInvalidMigration
+	}
+	// An unescaped "+" in pasted payloads is decoded as a space.
+	data := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	if data == "" {
+		return nil, batch, ErrInvalidMigration
+	}
+	var b []byte
+	if b, err = base64.StdEncoding.DecodeString(data); err != nil {
+		if b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return nil, batch, ErrInvalidMigration
+		}
+	}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, batch, ErrInvalidMigration
+		}
+		b = b[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var p []byte
+			if p, n = protowire.ConsumeBytes(b); n < 0 {
+				return nil, batch, ErrInvalidMigration
+			}
+			var item *Totp
+			if item, err = decodeMigrationParameters(p); err != nil {
+				return nil, batch, err
+			}
+			items = append(items, item)
+		case num >= 2 && num <= 5 && typ == protowire.VarintType:
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n < 0 {
+				return nil, batch, ErrInvalidMigration
+			}
+			switch num {
+			case 2:
+				batch.Version = int(int32(v))
+			case 3:
+				batch.Size = int(int32(v))
+			case 4:
+				batch.Index = int(int32(v))
+			case 5:
+				batch.Id = int32(v)
+			}
+		default:
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, batch, ErrInvalidMigration
+			}
+		}
+		b = b[n:]
+	}
+	return
+}
+
+func decodeMigrationParameters(b []byte) (x *Totp, err error) {
+	x = &Totp{
+		Window: DefaultWindow,
+	}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, ErrInvalidMigration
+		}
+		b = b[n:]
+		switch {
+		case num >= 1 && num <= 3 && typ == protowire.BytesType:
+			var v []byte
+			if v, n = protowire.ConsumeBytes(b); n < 0 {
+				return nil, ErrInvalidMigration
+			}
+			switch num {
+			case 1:
+				x.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(v)
+			case 2:
+				x.Account = string(v)
+			case 3:
+				x.Issuer = string(v)
+			}
+		case num >= 4 && num <= 7 && typ == protowire.VarintType:
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n < 0 {
+				return nil, ErrInvalidMigration
+			}
+			switch num {
+			case 4:
+				switch v {
+				case 0, 1:
+					x.Algorithm = SHA1
+				case 2:
+					x.Algorithm = SHA256
+				case 3:
+					x.Algorithm = SHA512
+				default:
+					return nil, ErrUnsupportedMigration
+				}
+			case 5:
+				switch v {
+				case 0, 1:
+					x.Digits = 6
+				case 2:
+					x.Digits = 8
+				default:
+					return nil, ErrUnsupportedMigration
+				}
+			case 6:
+				x.Hotp = v == 1
+			case 7:
+				x.Counter = int(v)
+			}
+		default:
+			if n = protowire.ConsumeFieldValue(num, typ, b); n < 0 {
+				return nil, ErrInvalidMigration
+			}
+		}
+		b = b[n:]
+	}
+	if x.Secret == "" {
+		return nil, ErrInvalidMigration
+	}
+	// Names are often exported as the full "Issuer:account" label.
+	if x.Issuer != "" {
+		x.Account = strings.TrimLeft(strings.TrimPrefix(x.Account, x.Issuer+":"), " ")
+	}
+	if !x.Hotp {
+		x.Counter = 0
+	}
+	return
+}
+
+func DecodeMigrations(uris []string) (items []*Totp, err error) {
+	var first MigrationBatch
+	seen := make(map[int]bool)
+	for i, uri := range uris {
+		var batch MigrationBatch
+		var v []*Totp
+		if v, batch, err = DecodeMigration(uri); err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = batch
+		}
+		if batch.Id != first.Id || batch.Size != first.Size || seen[batch.Index] ||
+			batch.Index < 0 || batch.Index >= max(first.Size, 1) {
+			return nil, ErrIncompleteMigration
+		}
+		seen[batch.Index] = true
+		items = append(items, v...)
+	}
+	if len(seen) != max(first.Size, 1) {
+		return nil, ErrIncompleteMigration
+	}
+	return
+}
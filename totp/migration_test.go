@@ -0,0 +1,136 @@
+package totp_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"google.golang.org/protobuf/encoding/protowire"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeMigration(t *testing.T) {
+	items, batch, err := totp.DecodeMigration("otpauth-migration://offline?data=CjUKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZSABKAEwAhABGAEgACgB")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.Version)
+	assert.Equal(t, 1, batch.Size)
+	assert.Equal(t, 0, batch.Index)
+	assert.Equal(t, int32(1), batch.Id)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "Example", items[0].Issuer)
+	assert.Equal(t, "alice@google.com", items[0].Account)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", items[0].Secret)
+	assert.Equal(t, totp.SHA1, items[0].Algorithm)
+	assert.Equal(t, 6, items[0].Digits)
+	assert.False(t, items[0].IsHotp())
+
+	_, _, err = totp.DecodeMigration("otpauth://totp/bob?secret=JBSWY3DPEHPK3PXP")
+	assert.ErrorIs(t, err, totp.ErrInvalidMigration)
+	_, _, err = totp.DecodeMigration("otpauth-migration://offline?data=%%%")
+	assert.ErrorIs(t, err, totp.ErrInvalidMigration)
+	_, _, err = totp.DecodeMigration("otpauth-migration://offline?data=CgQKAv8")
+	assert.ErrorIs(t, err, totp.ErrInvalidMigration)
+}
+
+func TestEncodeMigration(t *testing.T) {
+	items := []*totp.Totp{
+		{Issuer: "Example Co", Account: "alice@example.com", Secret: "2SH3V3GDW7ZNMGYE"},
+		{Account: "bob", Secret: "JBSWY3DPEHPK3PXP", Algorithm: totp.SHA256, Digits: 8},
+		{Issuer: "Token", Account: "carol", Secret: "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ", Hotp: true, Counter: 42},
+	}
+	uris, err := totp.EncodeMigration(items, 0)
+	assert.NoError(t, err)
+	assert.Len(t, uris, 1)
+	assert.True(t, strings.HasPrefix(uris[0], "otpauth-migration://offline?data="))
+
+	decoded, batch, err := totp.DecodeMigration(uris[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, batch.Size)
+	assert.Len(t, decoded, 3)
+	for i, v := range decoded {
+		assert.Equal(t, items[i].URI(), v.URI())
+		assert.Equal(t, items[i].Compute(1), v.Compute(1))
+		assert.Equal(t, totp.DefaultWindow, v.Window)
+	}
+
+	// Imported tokens authenticate without further setup.
+	now := time.Unix(1700000000, 0)
+	r, err := decoded[0].AuthenticateAt(now, fmt.Sprintf("%06d", decoded[0].Compute(int64(decoded[0].TimeStep(now)))))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	r, err = decoded[2].Authenticate(fmt.Sprintf("%06d", decoded[2].Compute(42)))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, 43, decoded[2].Counter)
+
+	_, err = totp.EncodeMigration([]*totp.Totp{{Secret: "JBSWY3DPEHPK3PXP", Period: 60}}, 0)
+	assert.ErrorIs(t, err, totp.ErrUnsupportedMigration)
+	_, err = totp.EncodeMigration([]*totp.Totp{{Secret: "JBSWY3DPEHPK3PXP", Digits: 7}}, 0)
+	assert.ErrorIs(t, err, totp.ErrUnsupportedMigration)
+}
+
+func TestMigrationBatches(t *testing.T) {
+	var items []*totp.Totp
+	for i := 0; i < 7; i++ {
+		x, err := totp.New(totp.SetIssuer("Example"), totp.SetAccount(fmt.Sprintf("user%d", i)))
+		assert.NoError(t, err)
+		items = append(items, x)
+	}
+	uris, err := totp.EncodeMigration(items, 3)
+	assert.NoError(t, err)
+	assert.Len(t, uris, 3)
+
+	var id int32
+	for i, uri := range uris {
+		_, batch, err := totp.DecodeMigration(uri)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, batch.Size)
+		assert.Equal(t, i, batch.Index)
+		if i == 0 {
+			id = batch.Id
+		}
+		assert.Equal(t, id, batch.Id)
+	}
+
+	// Batches may be scanned in any order.
+	decoded, err := totp.DecodeMigrations([]string{uris[2], uris[0], uris[1]})
+	assert.NoError(t, err)
+	assert.Len(t, decoded, 7)
+	secrets := make(map[string]bool)
+	for _, v := range decoded {
+		secrets[v.Secret] = true
+	}
+	for _, v := range items {
+		assert.True(t, secrets[v.Secret])
+	}
+
+	_, err = totp.DecodeMigrations(uris[:2])
+	assert.ErrorIs(t, err, totp.ErrIncompleteMigration)
+	_, err = totp.DecodeMigrations([]string{uris[0], uris[0], uris[1]})
+	assert.ErrorIs(t, err, totp.ErrIncompleteMigration)
+	other, err := totp.EncodeMigration(items, 3)
+	assert.NoError(t, err)
+	_, err = totp.DecodeMigrations([]string{uris[0], uris[1], other[2]})
+	assert.ErrorIs(t, err, totp.ErrIncompleteMigration)
+
+	// Indices outside of the batch size do not complete it.
+	var params []byte
+	params = protowire.AppendTag(params, 1, protowire.BytesType)
+	params = protowire.AppendBytes(params, []byte("Hello!"))
+	var payload []byte
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, params)
+	for _, v := range [][2]uint64{{2, 1}, {3, 3}, {4, 7}, {5, uint64(id)}} {
+		payload = protowire.AppendTag(payload, protowire.Number(v[0]), protowire.VarintType)
+		payload = protowire.AppendVarint(payload, v[1])
+	}
+	outside := "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+	_, batch, err := totp.DecodeMigration(outside)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, batch.Index)
+	_, err = totp.DecodeMigrations([]string{uris[0], uris[1], outside})
+	assert.ErrorIs(t, err, totp.ErrIncompleteMigration)
+}
@@ -0,0 +1,164 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"testing"
+	"time"
+)
+
+var (
+	ocraKey20 = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	ocraKey32 = base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012"))
+	ocraKey64 = base32.StdEncoding.EncodeToString(
+		[]byte("1234567890123456789012345678901234567890123456789012345678901234"))
+	ocraTime = time.Unix(0x132d0b6*60, 0)
+)
+
+func TestParseOcraSuite(t *testing.T) {
+	x, err := totp.ParseOcraSuite("OCRA-1:HOTP-SHA512-8:C-QN08-PSHA1-S128-T1M")
+	assert.NoError(t, err)
+	assert.Equal(t, totp.SHA512, x.Algorithm)
+	assert.Equal(t, 8, x.Digits)
+	assert.True(t, x.Counter)
+	assert.Equal(t, byte('N'), x.Question)
+	assert.Equal(t, 8, x.QuestionLength)
+	assert.Equal(t, totp.SHA1, x.Password)
+	assert.Equal(t, 128, x.Session)
+	assert.Equal(t, time.Minute, x.TimeStep)
+
+	x, err = totp.ParseOcraSuite("OCRA-1:HOTP-SHA1-6:QA10-S-T30S")
+	assert.NoError(t, err)
+	assert.False(t, x.Counter)
+	assert.Equal(t, 64, x.Session)
+	assert.Equal(t, time.Second*30, x.TimeStep)
+
+	for _, v := range []string{
+		"",
+		"OCRA-2:HOTP-SHA1-6:QN08",
+		"OCRA-1:TOTP-SHA1-6:QN08",
+		"OCRA-1:HOTP-MD5-6:QN08",
+		"OCRA-1:HOTP-SHA1-3:QN08",
+		"OCRA-1:HOTP-SHA1-6:C",
+		"OCRA-1:HOTP-SHA1-6:QX08",
+		"OCRA-1:HOTP-SHA1-6:QN65",
+		"OCRA-1:HOTP-SHA1-6:QN08-PMD5",
+		"OCRA-1:HOTP-SHA1-6:QN08-T1X",
+		"OCRA-1:HOTP-SHA1-6:QN08-T1M-PSHA1",
+		"OCRA-1:HOTP-SHA1-6:QN08-X",
+		"OCRA-1:HOTP-SHA1-6:QN08-",
+	} {
+		_, err = totp.ParseOcraSuite(v)
+		assert.ErrorIs(t, err, totp.ErrInvalidSuite, v)
+	}
+}
+
+func TestOcraRFC6287(t *testing.T) {
+	values := []struct {
+		suite  string
+		secret string
+		input  totp.OcraInput
+		result string
+	}{
+		{"OCRA-1:HOTP-SHA1-6:QN08", ocraKey20, totp.OcraInput{Question: "00000000"}, "237653"},
+		{"OCRA-1:HOTP-SHA1-6:QN08", ocraKey20, totp.OcraInput{Question: "11111111"}, "243178"},
+		{"OCRA-1:HOTP-SHA1-6:QN08", ocraKey20, totp.OcraInput{Question: "22222222"}, "653583"},
+		{"OCRA-1:HOTP-SHA1-6:QN08", ocraKey20, totp.OcraInput{Question: "33333333"}, "740991"},
+		{"OCRA-1:HOTP-SHA1-6:QN08", ocraKey20, totp.OcraInput{Question: "99999999"}, "294470"},
+		{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", ocraKey32,
+			totp.OcraInput{Counter: 0, Question: "12345678", Password: "1234"}, "65347737"},
+		{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", ocraKey32,
+			totp.OcraInput{Counter: 1, Question: "12345678", Password: "1234"}, "86775851"},
+		{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", ocraKey32,
+			totp.OcraInput{Counter: 9, Question: "12345678", Password: "1234"}, "08522129"},
+		{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", ocraKey32,
+			totp.OcraInput{Question: "00000000", Password: "1234"}, "83238735"},
+		{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", ocraKey32,
+			totp.OcraInput{Question: "44444444", Password: "1234"}, "86807031"},
+		{"OCRA-1:HOTP-SHA512-8:C-QN08", ocraKey64,
+			totp.OcraInput{Counter: 0, Question: "00000000"}, "07016083"},
+		{"OCRA-1:HOTP-SHA512-8:C-QN08", ocraKey64,
+			totp.OcraInput{Counter: 5, Question: "55555555"}, "34205738"},
+		{"OCRA-1:HOTP-SHA512-8:QN08-T1M", ocraKey64,
+			totp.OcraInput{Question: "00000000", Time: ocraTime}, "95209754"},
+		{"OCRA-1:HOTP-SHA512-8:QN08-T1M", ocraKey64,
+			totp.OcraInput{Question: "44444444", Time: ocraTime}, "36209546"},
+		{"OCRA-1:HOTP-SHA256-8:QA08", ocraKey32,
+			totp.OcraInput{Question: "CLI22220SRV11110"}, "28247970"},
+		{"OCRA-1:HOTP-SHA256-8:QA08", ocraKey32,
+			totp.OcraInput{Question: "SRV11110CLI22220"}, "15510767"},
+		{"OCRA-1:HOTP-SHA256-8:QA08", ocraKey32, totp.OcraInput{Question: "SIG10000"}, "53095496"},
+		{"OCRA-1:HOTP-SHA512-8:QA10-T1M", ocraKey64,
+			totp.OcraInput{Question: "SIG1000000", Time: ocraTime}, "77537423"},
+	}
+	for _, v := range values {
+		suite, err := totp.ParseOcraSuite(v.suite)
+		assert.NoError(t, err)
+		r, err := suite.Compute(v.secret, v.input)
+		assert.NoError(t, err)
+		assert.Equal(t, v.result, r, v.suite)
+	}
+}
+
+func TestOcraInvalidInput(t *testing.T) {
+	suite, err := totp.ParseOcraSuite("OCRA-1:HOTP-SHA1-6:QN08-PSHA1-S064")
+	assert.NoError(t, err)
+	for _, v := range []totp.OcraInput{
+		{Question: "123"},
+		{Question: "abcdefgh"},
+		{Question: "12345678", PasswordHash: []byte("short")},
+		{Question: "12345678", Session: make([]byte, 65)},
+	} {
+		_, err = suite.Compute(ocraKey20, v)
+		assert.ErrorIs(t, err, totp.ErrInvalidInput)
+	}
+	_, err = suite.Compute("1!", totp.OcraInput{Question: "12345678"})
+	assert.Error(t, err)
+
+	hex, err := totp.ParseOcraSuite("OCRA-1:HOTP-SHA1-6:QH08")
+	assert.NoError(t, err)
+	_, err = hex.Compute(ocraKey20, totp.OcraInput{Question: "zzzz"})
+	assert.ErrorIs(t, err, totp.ErrInvalidInput)
+	r1, err := hex.Compute(ocraKey20, totp.OcraInput{Question: "abcde"})
+	assert.NoError(t, err)
+	r2, err := hex.Compute(ocraKey20, totp.OcraInput{Question: "ABCDE0"})
+	assert.NoError(t, err)
+	assert.Equal(t, r1, r2)
+}
+
+func TestOcraVerify(t *testing.T) {
+	x, err := totp.NewOcra("OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", ocraKey32)
+	assert.NoError(t, err)
+	input := totp.OcraInput{Question: "12345678", Password: "1234"}
+	ok, err := x.Verify(input, "86775851")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), x.Counter)
+	ok, err = x.Verify(input, "86775851")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(2), x.Counter)
+	ok, err = x.Verify(totp.OcraInput{Question: "12345678", Password: "4321"}, "78192410")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	x, err = totp.NewOcra("OCRA-1:HOTP-SHA512-8:QN08-T1M", ocraKey64)
+	assert.NoError(t, err)
+	x.Clock = totp.ClockFunc(func() time.Time { return ocraTime.Add(time.Minute) })
+	ok, err = x.Verify(totp.OcraInput{Question: "22222222"}, "22048402")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	x.Clock = totp.ClockFunc(func() time.Time { return ocraTime.Add(time.Minute * 2) })
+	ok, err = x.Verify(totp.OcraInput{Question: "22222222"}, "22048402")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = x.Verify(totp.OcraInput{Question: "22222222", Time: ocraTime}, "22048402")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = x.Verify(totp.OcraInput{Question: "1"}, "22048402")
+	assert.ErrorIs(t, err, totp.ErrInvalidInput)
+	_, err = totp.NewOcra("OCRA-1", ocraKey64)
+	assert.ErrorIs(t, err, totp.ErrInvalidSuite)
+}
@@ -0,0 +1,123 @@
+package totp
+
+import (
+	"errors"
+	"github.com/weplanx/go/qrcode"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidURI = errors.New("invalid otpauth key uri")
+)
+
+func (x *Totp) URI() string {
+	label := x.Account
+	if x.Issuer != "" {
+		label = x.Issuer + ":" + x.Account
+	}
+	query := url.Values{}
+	query.Set("secret", strings.TrimRight(strings.ToUpper(strings.ReplaceAll(x.Secret, " ", "")), "="))
+	if x.Issuer != "" {
+		query.Set("issuer", x.Issuer)
+	}
+	if x.Algorithm != "" && x.Algorithm != SHA1 {
+		query.Set("algorithm", string(x.Algorithm))
+	}
+	if x.GetDigits() != DefaultDigits {
+		query.Set("digits", strconv.Itoa(x.GetDigits()))
+	}
+	kind := "totp"
+	if x.IsHotp() {
+		kind = "hotp"
+		query.Set("counter", strconv.Itoa(x.Counter))
+	} else if x.GetPeriod() != DefaultPeriod {
+		query.Set("period", strconv.Itoa(x.GetPeriod()))
+	}
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     kind,
+		Path:     "/" + label,
+		RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20"),
+	}
+	return u.String()
+}
+
+func ParseURI(uri string) (x *Totp, err error) {
+	var u *url.URL
+	if u, err = url.Parse(uri); err != nil {
+		return nil, ErrInvalidURI
+	}
+	if u.Scheme != "otpauth" || (u.Host != "totp" && u.Host != "hotp") {
+		return nil, ErrInvalidURI
+	}
+	x = &Totp{
+		Window: DefaultWindow,
+		Hotp:   u.Host == "hotp",
+	}
+	label := strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(label, ":"); i != -1 {
+		x.Issuer = label[:i]
+		label = strings.TrimLeft(label[i+1:], " ")
+	}
+	x.Account = label
+
+	query := u.Query()
+	if issuer := query.Get("issuer"); issuer != "" {
+		x.Issuer = issuer
+	}
+	x.Secret = strings.ToUpper(query.Get("secret"))
+	if x.Secret == "" {
+		return nil, ErrInvalidURI
+	}
+	if _, err = DecodeSecret(x.Secret); err != nil {
+		return nil, ErrInvalidURI
+	}
+	if v := query.Get("algorithm"); v != "" {
+		x.Algorithm = Algorithm(strings.ToUpper(v))
+		if x.Algorithm.Hash() == nil {
+			return nil, ErrInvalidURI
+		}
+	}
+	if x.Digits, err = parseParam(query, "digits"); err != nil || x.Digits < 0 || x.Digits > 10 {
+		return nil, ErrInvalidURI
+	}
+	if x.Period, err = parseParam(query, "period"); err != nil || x.Period < 0 {
+		return nil, ErrInvalidURI
+	}
+	if x.Hotp {
+		if x.Counter, err = parseParam(query, "counter"); err != nil || x.Counter < 0 {
+			return nil, ErrInvalidURI
+		}
+	}
+	return
+}
+
+func parseParam(query url.Values, name string) (int, error) {
+	v := query.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func (x *Totp) QRCode(level qrcode.Level) (*qrcode.QRCode, error) {
+	return qrcode.New(x.URI(), level)
+}
+
+func (x *Totp) PNG(scale int) (_ []byte, err error) {
+	var code *qrcode.QRCode
+	if code, err = x.QRCode(qrcode.M); err != nil {
+		return
+	}
+	return code.PNG(scale)
+}
+
+func (x *Totp) SVG(scale int) (_ string, err error) {
+	var code *qrcode.QRCode
+	if code, err = x.QRCode(qrcode.M); err != nil {
+		return
+	}
+	return code.SVG(scale), nil
+}
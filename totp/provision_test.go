@@ -0,0 +1,115 @@
+package totp_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/qrcode"
+	"github.com/weplanx/go/totp"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURI(t *testing.T) {
+	x := &totp.Totp{
+		Issuer:  "Example Co",
+		Account: "alice@example.com",
+		Secret:  "2SH3V3GDW7ZNMGYE",
+	}
+	assert.Equal(t,
+		"otpauth://totp/Example%20Co:alice@example.com?issuer=Example%20Co&secret=2SH3V3GDW7ZNMGYE",
+		x.URI(),
+	)
+
+	x.Algorithm = totp.SHA512
+	x.Digits = 8
+	x.Period = 60
+	assert.Equal(t,
+		"otpauth://totp/Example%20Co:alice@example.com?algorithm=SHA512&digits=8&issuer=Example%20Co&period=60&secret=2SH3V3GDW7ZNMGYE",
+		x.URI(),
+	)
+
+	h := &totp.Totp{Account: "bob", Secret: "2SH3V3GDW7ZNMGYE", Hotp: true}
+	assert.Equal(t, "otpauth://hotp/bob?counter=0&secret=2SH3V3GDW7ZNMGYE", h.URI())
+}
+
+func TestParseURI(t *testing.T) {
+	x, err := totp.ParseURI("otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60")
+	assert.NoError(t, err)
+	assert.Equal(t, "ACME Co", x.Issuer)
+	assert.Equal(t, "john.doe@email.com", x.Account)
+	assert.Equal(t, "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ", x.Secret)
+	assert.Equal(t, totp.SHA256, x.Algorithm)
+	assert.Equal(t, 8, x.Digits)
+	assert.Equal(t, 60, x.Period)
+	assert.False(t, x.IsHotp())
+	assert.NotEqual(t, -1, x.Compute(1))
+
+	y, err := totp.ParseURI(x.URI())
+	assert.NoError(t, err)
+	assert.Equal(t, x, y)
+
+	x, err = totp.ParseURI("otpauth://hotp/Example:%20alice?secret=2sh3v3gdw7znmgye&counter=42")
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", x.Issuer)
+	assert.Equal(t, "alice", x.Account)
+	assert.True(t, x.IsHotp())
+	assert.Equal(t, 42, x.Counter)
+
+	for _, v := range []string{
+		"http://totp/alice?secret=2SH3V3GDW7ZNMGYE",
+		"otpauth://motp/alice?secret=2SH3V3GDW7ZNMGYE",
+		"otpauth://totp/alice",
+		"otpauth://totp/alice?secret=1!",
+		"otpauth://totp/alice?secret=2SH3V3GDW7ZNMGYE&algorithm=MD5",
+		"otpauth://totp/alice?secret=2SH3V3GDW7ZNMGYE&digits=x",
+		"otpauth://totp/alice?secret=2SH3V3GDW7ZNMGYE&period=-30",
+		"otpauth://hotp/alice?secret=2SH3V3GDW7ZNMGYE&counter=x",
+		"%",
+	} {
+		_, err = totp.ParseURI(v)
+		assert.ErrorIs(t, err, totp.ErrInvalidURI, v)
+	}
+}
+
+func TestParseURIAuthenticate(t *testing.T) {
+	x, err := totp.ParseURI("otpauth://totp/alice?secret=2SH3V3GDW7ZNMGYE")
+	assert.NoError(t, err)
+	assert.Equal(t, totp.DefaultWindow, x.Window)
+	now := time.Unix(1700000000, 0)
+	code := fmt.Sprintf("%06d", x.Compute(int64(x.TimeStep(now)-1)))
+	r, err := x.AuthenticateAt(now, code)
+	assert.NoError(t, err)
+	assert.True(t, r)
+
+	x, err = totp.ParseURI("otpauth://hotp/alice?secret=2SH3V3GDW7ZNMGYE&counter=5")
+	assert.NoError(t, err)
+	assert.Equal(t, totp.DefaultWindow, x.Window)
+	r, err = x.Authenticate(fmt.Sprintf("%06d", x.Compute(5)))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, 6, x.Counter)
+}
+
+func TestQRCode(t *testing.T) {
+	x := &totp.Totp{
+		Issuer:  "Example",
+		Account: "alice@example.com",
+		Secret:  "2SH3V3GDW7ZNMGYE",
+	}
+	code, err := x.QRCode(qrcode.H)
+	assert.NoError(t, err)
+	assert.Equal(t, qrcode.H, code.Level)
+
+	b, err := x.PNG(4)
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, img.Bounds().Dx(), img.Bounds().Dy())
+
+	svg, err := x.SVG(4)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+}
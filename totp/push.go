@@ -0,0 +1,295 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrPushNotExists      = errors.New("the push challenge does not exists")
+	ErrPushLimited        = errors.New("too many outstanding push challenges")
+	ErrPushResolved       = errors.New("the push challenge has already been resolved")
+	ErrPushNumberMismatch = errors.New("the push challenge number does not match")
+	ErrPushNotApproved    = errors.New("the push challenge has not been approved")
+)
+
+type PushStatus string
+
+const (
+	PushPending  PushStatus = "pending"
+	PushApproved PushStatus = "approved"
+	PushDenied   PushStatus = "denied"
+	PushExpired  PushStatus = "expired"
+	PushConsumed PushStatus = "consumed"
+)
+
+type PushChallenge struct {
+	Id         string
+	Subject    string
+	Number     int
+	Status     PushStatus
+	Metadata   map[string]string
+	CreateTime time.Time
+}
+
+type Push struct {
+	RDb      *redis.Client
+	TTL      time.Duration
+	Limit    int
+	Interval time.Duration
+}
+
+func NewPush(rdb *redis.Client, ttl time.Duration, limit int) *Push {
+	return &Push{RDb: rdb, TTL: ttl, Limit: limit, Interval: time.Millisecond * 500}
+}
+
+func (x *Push) Key(id string) string {
+	return fmt.Sprintf(`totp:push:%s`, id)
+}
+
+func (x *Push) SubjectKey(subject string) string {
+	return fmt.Sprintf(`totp:push:subject:%s`, subject)
+}
+
+var resolvePush = redis.NewScript(`
+local status = redis.call('HGET', KEYS[1], 'status')
+if not status then
+	return 0
+end
+if status ~= ARGV[1] then
+	return -1
+end
+redis.call('HSET', KEYS[1], 'status', ARGV[2])
+return 1
+`)
+
+func (x *Push) Create(ctx context.Context, subject string, metadata map[string]string) (data *PushChallenge, err error) {
+	if subject == "" {
+		return nil, ErrMissingSubject
+	}
+	b := make([]byte, 16)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	var n *big.Int
+	if n, err = rand.Int(rand.Reader, big.NewInt(90)); err != nil {
+		return
+	}
+	data = &PushChallenge{
+		Id:         hex.EncodeToString(b),
+		Subject:    subject,
+		Number:     int(n.Int64()) + 10,
+		Status:     PushPending,
+		Metadata:   metadata,
+		CreateTime: time.Now(),
+	}
+
+	// Reserve a slot first, so concurrent creates can not exceed the limit.
+	key := x.SubjectKey(subject)
+	now := data.CreateTime.UnixMilli()
+	if err = x.RDb.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now, 10)).Err(); err != nil {
+		return nil, err
+	}
+	member := redis.Z{Score: float64(data.CreateTime.Add(x.TTL).UnixMilli()), Member: data.Id}
+	if err = x.RDb.ZAdd(ctx, key, member).Err(); err != nil {
+		return nil, err
+	}
+	var count int64
+	if count, err = x.RDb.ZCard(ctx, key).Result(); err != nil {
+		return nil, err
+	}
+	if x.Limit > 0 && count > int64(x.Limit) {
+		x.RDb.ZRem(ctx, key, data.Id)
+		return nil, ErrPushLimited
+	}
+
+	var meta []byte
+	if meta, err = json.Marshal(metadata); err != nil {
+		return nil, err
+	}
+	if _, err = x.RDb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, x.Key(data.Id), map[string]interface{}{
+			"subject":     data.Subject,
+			"number":      data.Number,
+			"status":      string(data.Status),
+			"metadata":    meta,
+			"create_time": data.CreateTime.UnixMilli(),
+		})
+		p.Expire(ctx, x.Key(data.Id), x.TTL)
+		p.Expire(ctx, key, x.TTL)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *Push) Get(ctx context.Context, id string) (data *PushChallenge, err error) {
+	var values map[string]string
+	if values, err = x.RDb.HGetAll(ctx, x.Key(id)).Result(); err != nil {
+		return
+	}
+	if len(values) == 0 {
+		return nil, ErrPushNotExists
+	}
+	data = &PushChallenge{
+		Id:      id,
+		Subject: values["subject"],
+		Status:  PushStatus(values["status"]),
+	}
+	if data.Number, err = strconv.Atoi(values["number"]); err != nil {
+		return nil, err
+	}
+	var ms int64
+	if ms, err = strconv.ParseInt(values["create_time"], 10, 64); err != nil {
+		return nil, err
+	}
+	data.CreateTime = time.UnixMilli(ms)
+	if err = json.Unmarshal([]byte(values["metadata"]), &data.Metadata); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *Push) Pending(ctx context.Context, subject string) (data []*PushChallenge, err error) {
+	key := x.SubjectKey(subject)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err = x.RDb.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+		return
+	}
+	var ids []string
+	if ids, err = x.RDb.ZRange(ctx, key, 0, -1).Result(); err != nil {
+		return
+	}
+	data = make([]*PushChallenge, 0, len(ids))
+	for _, id := range ids {
+		var v *PushChallenge
+		if v, err = x.Get(ctx, id); err != nil {
+			if errors.Is(err, ErrPushNotExists) {
+				continue
+			}
+			return nil, err
+		}
+		if v.Status == PushPending {
+			data = append(data, v)
+		}
+	}
+	return data, nil
+}
+
+func (x *Push) Status(ctx context.Context, id string) (PushStatus, error) {
+	status, err := x.RDb.HGet(ctx, x.Key(id), "status").Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return PushExpired, nil
+		}
+		return "", err
+	}
+	return PushStatus(status), nil
+}
+
+func (x *Push) Approve(ctx context.Context, subject string, id string, number int) (err error) {
+	var data *PushChallenge
+	if data, err = x.Get(ctx, id); err != nil {
+		return
+	}
+	// Only devices signed in as the same subject may answer.
+	if data.Subject != subject {
+		return ErrPushNotExists
+	}
+	if data.Number != number {
+		// A wrong guess denies the challenge, so it can not be retried.
+		if err = x.resolve(ctx, data, PushDenied); err != nil {
+			return
+		}
+		return ErrPushNumberMismatch
+	}
+	return x.resolve(ctx, data, PushApproved)
+}
+
+func (x *Push) Deny(ctx context.Context, subject string, id string) (err error) {
+	var data *PushChallenge
+	if data, err = x.Get(ctx, id); err != nil {
+		return
+	}
+	if data.Subject != subject {
+		return ErrPushNotExists
+	}
+	return x.resolve(ctx, data, PushDenied)
+}
+
+func (x *Push) resolve(ctx context.Context, data *PushChallenge, status PushStatus) (err error) {
+	var n int64
+	if n, err = resolvePush.Run(ctx, x.RDb, []string{x.Key(data.Id)}, string(PushPending), string(status)).Int64(); err != nil {
+		return
+	}
+	switch n {
+	case 0:
+		return ErrPushNotExists
+	case -1:
+		return ErrPushResolved
+	}
+	x.RDb.ZRem(ctx, x.SubjectKey(data.Subject), data.Id)
+	return
+}
+
+// Consume completes the login of an approved challenge, an approval is only consumed once.
+func (x *Push) Consume(ctx context.Context, id string) (err error) {
+	var n int64
+	if n, err = resolvePush.Run(ctx, x.RDb, []string{x.Key(id)}, string(PushApproved), string(PushConsumed)).Int64(); err != nil {
+		return
+	}
+	switch n {
+	case 0:
+		return ErrPushNotExists
+	case -1:
+		return ErrPushNotApproved
+	}
+	return
+}
+
+func (x *Push) Wait(ctx context.Context, id string) (status PushStatus, err error) {
+	ticker := time.NewTicker(x.Interval)
+	defer ticker.Stop()
+	for {
+		if status, err = x.Status(ctx, id); err != nil {
+			return
+		}
+		if status == PushApproved {
+			// Only the waiter that consumes the approval may treat it as approved.
+			if err = x.Consume(ctx, id); err != nil {
+				if errors.Is(err, ErrPushNotExists) {
+					return PushExpired, nil
+				}
+				if errors.Is(err, ErrPushNotApproved) {
+					return x.Status(ctx, id)
+				}
+				return "", err
+			}
+			return
+		}
+		if status != PushPending {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (x *Push) Delete(ctx context.Context, id string) int64 {
+	if data, err := x.Get(ctx, id); err == nil {
+		x.RDb.ZRem(ctx, x.SubjectKey(data.Subject), id)
+	}
+	return x.RDb.Del(ctx, x.Key(id)).Val()
+}
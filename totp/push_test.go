@@ -0,0 +1,154 @@
+package totp_test
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"testing"
+	"time"
+)
+
+func TestPushApprove(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewPush(rdb, time.Minute, 3)
+	x.Interval = time.Millisecond * 10
+
+	data, err := x.Create(ctx, "push-approve", map[string]string{"ip": "127.0.0.1"})
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, data.Number, 10)
+	assert.LessOrEqual(t, data.Number, 99)
+	defer x.Delete(ctx, data.Id)
+
+	pending, err := x.Pending(ctx, "push-approve")
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, data.Id, pending[0].Id)
+	assert.Equal(t, data.Number, pending[0].Number)
+	assert.Equal(t, "127.0.0.1", pending[0].Metadata["ip"])
+
+	status, err := x.Status(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushPending, status)
+
+	// Another subject can not answer the challenge.
+	err = x.Approve(ctx, "someone-else", data.Id, data.Number)
+	assert.ErrorIs(t, err, totp.ErrPushNotExists)
+
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		assert.NoError(t, x.Approve(ctx, "push-approve", data.Id, data.Number))
+	}()
+	status, err = x.Wait(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushApproved, status)
+
+	// Waiting consumed the approval, it can not complete another login.
+	status, err = x.Status(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushConsumed, status)
+	status, err = x.Wait(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushConsumed, status)
+	err = x.Consume(ctx, data.Id)
+	assert.ErrorIs(t, err, totp.ErrPushNotApproved)
+
+	err = x.Deny(ctx, "push-approve", data.Id)
+	assert.ErrorIs(t, err, totp.ErrPushResolved)
+	pending, err = x.Pending(ctx, "push-approve")
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestPushConsume(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewPush(rdb, time.Minute, 3)
+
+	data, err := x.Create(ctx, "push-consume", nil)
+	assert.NoError(t, err)
+	defer x.Delete(ctx, data.Id)
+	err = x.Consume(ctx, data.Id)
+	assert.ErrorIs(t, err, totp.ErrPushNotApproved)
+
+	assert.NoError(t, x.Approve(ctx, "push-consume", data.Id, data.Number))
+	assert.NoError(t, x.Consume(ctx, data.Id))
+	err = x.Consume(ctx, data.Id)
+	assert.ErrorIs(t, err, totp.ErrPushNotApproved)
+
+	assert.Equal(t, int64(1), x.Delete(ctx, data.Id))
+	err = x.Consume(ctx, data.Id)
+	assert.ErrorIs(t, err, totp.ErrPushNotExists)
+}
+
+func TestPushDeny(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewPush(rdb, time.Minute, 3)
+
+	data, err := x.Create(ctx, "push-deny", nil)
+	assert.NoError(t, err)
+	defer x.Delete(ctx, data.Id)
+	assert.NoError(t, x.Deny(ctx, "push-deny", data.Id))
+	status, err := x.Status(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushDenied, status)
+
+	// Picking the wrong number denies the challenge.
+	other, err := x.Create(ctx, "push-deny", nil)
+	assert.NoError(t, err)
+	defer x.Delete(ctx, other.Id)
+	err = x.Approve(ctx, "push-deny", other.Id, other.Number%99+10)
+	assert.ErrorIs(t, err, totp.ErrPushNumberMismatch)
+	err = x.Approve(ctx, "push-deny", other.Id, other.Number)
+	assert.ErrorIs(t, err, totp.ErrPushResolved)
+	status, err = x.Wait(ctx, other.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushDenied, status)
+}
+
+func TestPushExpire(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewPush(rdb, time.Minute, 3)
+	x.Interval = time.Millisecond * 10
+
+	data, err := x.Create(ctx, "push-expire", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), x.Delete(ctx, data.Id))
+	status, err := x.Status(ctx, data.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, totp.PushExpired, status)
+	err = x.Approve(ctx, "push-expire", data.Id, data.Number)
+	assert.ErrorIs(t, err, totp.ErrPushNotExists)
+
+	data, err = x.Create(ctx, "push-expire", nil)
+	assert.NoError(t, err)
+	defer x.Delete(ctx, data.Id)
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	_, err = x.Wait(timeout, data.Id)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = x.Create(ctx, "", nil)
+	assert.ErrorIs(t, err, totp.ErrMissingSubject)
+}
+
+func TestPushLimit(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewPush(rdb, time.Minute, 2)
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		data, err := x.Create(ctx, "push-limit", nil)
+		assert.NoError(t, err)
+		ids = append(ids, data.Id)
+	}
+	_, err := x.Create(ctx, "push-limit", nil)
+	assert.ErrorIs(t, err, totp.ErrPushLimited)
+
+	// Resolved challenges release their slot.
+	assert.NoError(t, x.Deny(ctx, "push-limit", ids[0]))
+	data, err := x.Create(ctx, "push-limit", nil)
+	assert.NoError(t, err)
+	ids = append(ids, data.Id)
+	for _, id := range ids {
+		x.Delete(ctx, id)
+	}
+}
@@ -0,0 +1,43 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	ErrMissingSubject = errors.New("subject is required for shared state")
+)
+
+type Replay interface {
+	Use(ctx context.Context, subject string, step int64, ttl time.Duration) (bool, error)
+}
+
+type RedisReplay struct {
+	RDb *redis.Client
+}
+
+func NewRedisReplay(rdb *redis.Client) *RedisReplay {
+	return &RedisReplay{RDb: rdb}
+}
+
+func (x *RedisReplay) Key(subject string, step int64) string {
+	return fmt.Sprintf(`totp:used:%s:%d`, subject, step)
+}
+
+func (x *RedisReplay) Use(ctx context.Context, subject string, step int64, ttl time.Duration) (bool, error) {
+	return x.RDb.SetNX(ctx, x.Key(subject, step), 1, ttl).Result()
+}
+
+func (x *Totp) use(ctx context.Context, step int) (bool, error) {
+	if x.Subject == "" {
+		return false, ErrMissingSubject
+	}
+	// A step stays acceptable for at most Window+1 periods, and drift can
+	// shift the accepted range by MaxDrift steps either way.
+	ttl := time.Duration((x.Window+2*max(x.MaxDrift, 0)+1)*x.GetPeriod()) * time.Second
+	return x.Replay.Use(ctx, x.Subject, int64(step), ttl)
+}
@@ -0,0 +1,94 @@
+package totp_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedisReplay(t *testing.T) {
+	ctx := context.TODO()
+	x := totp.NewRedisReplay(rdb)
+	x.RDb.Del(ctx, x.Key("dev", 100))
+	ok, err := x.Use(ctx, "dev", 100, time.Second*60)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = x.Use(ctx, "dev", 100, time.Second*60)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ttl := x.RDb.TTL(ctx, x.Key("dev", 100)).Val()
+	assert.Greater(t, ttl, time.Duration(0))
+	assert.Equal(t, int64(1), x.RDb.Del(ctx, x.Key("dev", 100)).Val())
+}
+
+func TestAuthenticateReplay(t *testing.T) {
+	ctx := context.TODO()
+	replay := totp.NewRedisReplay(rdb)
+	ts := time.Now().UTC().Unix() / 30
+	for i := ts - 1; i <= ts+1; i++ {
+		replay.RDb.Del(ctx, replay.Key("replay", i))
+	}
+	code := fmt.Sprintf("%06d", totp.Compute("2SH3V3GDW7ZNMGYE", ts))
+
+	// Two instances holding their own copy of the same user.
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			x := &totp.Totp{
+				Secret:  "2SH3V3GDW7ZNMGYE",
+				Window:  3,
+				Subject: "replay",
+				Replay:  replay,
+			}
+			r, err := x.AuthenticateContext(ctx, code)
+			assert.NoError(t, err)
+			if r {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load())
+
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Replay: replay}
+	_, err := x.Authenticate(code)
+	assert.ErrorIs(t, err, totp.ErrMissingSubject)
+}
+
+func TestReplayDrift(t *testing.T) {
+	replay := &memoryReplay{used: make(map[int64]bool)}
+	x := &totp.Totp{
+		Secret:        "2SH3V3GDW7ZNMGYE",
+		Window:        3,
+		MaxDrift:      2,
+		DisallowReuse: []int{},
+		Subject:       "drift",
+		Replay:        replay,
+	}
+	now := time.Unix(300000, 0)
+	ts := x.TimeStep(now)
+
+	// Another instance already used the step, the local state stays put.
+	replay.used[int64(ts+1)] = true
+	r, err := x.AuthenticateAt(now, fmt.Sprintf("%06d", x.Compute(int64(ts+1))))
+	assert.NoError(t, err)
+	assert.False(t, r)
+	assert.Equal(t, 0, x.Drift)
+	assert.Empty(t, x.DisallowReuse)
+
+	r, err = x.AuthenticateAt(now, fmt.Sprintf("%06d", x.Compute(int64(ts-1))))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, -1, x.Drift)
+	assert.Equal(t, []int{ts - 1}, x.DisallowReuse)
+	// The key outlives the widest range drift can accept the step in.
+	assert.Equal(t, time.Duration((3+2*2+1)*30)*time.Second, replay.ttl)
+}
@@ -0,0 +1,88 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidScratchHash = errors.New("unable to parse the scratch code hash")
+	ErrMissingScratchKey  = errors.New("scratch key is required for hashed scratch codes")
+)
+
+func HashScratchCode(key []byte, code int) (string, error) {
+	// Without a key the small code space is trivially brute-forced.
+	if len(key) == 0 {
+		return "", ErrMissingScratchKey
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(scratchMac(key, salt, code)), nil
+}
+
+func VerifyScratchCode(key []byte, code int, hashed string) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrMissingScratchKey
+	}
+	salt, mac, ok := strings.Cut(hashed, "$")
+	if !ok {
+		return false, ErrInvalidScratchHash
+	}
+	var err error
+	var s, m []byte
+	if s, err = base64.RawStdEncoding.Strict().DecodeString(salt); err != nil {
+		return false, ErrInvalidScratchHash
+	}
+	if m, err = base64.RawStdEncoding.Strict().DecodeString(mac); err != nil {
+		return false, ErrInvalidScratchHash
+	}
+	return subtle.ConstantTimeCompare(m, scratchMac(key, s, code)) == 1, nil
+}
+
+func scratchMac(key []byte, salt []byte, code int) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(salt)
+	h.Write([]byte(strconv.Itoa(code)))
+	return h.Sum(nil)
+}
+
+func (x *Totp) GenerateHashedScratchCodes(n int) (codes []int, err error) {
+	if len(x.ScratchKey) == 0 {
+		return nil, ErrMissingScratchKey
+	}
+	if codes, err = GenerateScratchCodes(n); err != nil {
+		return
+	}
+	hashed := make([]string, len(codes))
+	for i, v := range codes {
+		if hashed[i], err = HashScratchCode(x.ScratchKey, v); err != nil {
+			return nil, err
+		}
+	}
+	x.HashedScratchCodes = hashed
+	return
+}
+
+func (x *Totp) CheckHashedScratchCodes(code int) bool {
+	// Every entry is checked so the timing does not reveal the position.
+	matched := -1
+	for i, v := range x.HashedScratchCodes {
+		if ok, _ := VerifyScratchCode(x.ScratchKey, code, v); ok && matched == -1 {
+			matched = i
+		}
+	}
+	if matched == -1 {
+		return false
+	}
+	x.HashedScratchCodes = append(x.HashedScratchCodes[:matched], x.HashedScratchCodes[matched+1:]...)
+	return true
+}
@@ -0,0 +1,77 @@
+package totp_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHashScratchCode(t *testing.T) {
+	key := []byte("8QuyG9RmUwBzF2tC")
+	hashed, err := totp.HashScratchCode(key, 12345678)
+	assert.NoError(t, err)
+	assert.NotContains(t, hashed, "12345678")
+	other, err := totp.HashScratchCode(key, 12345678)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hashed, other)
+
+	ok, err := totp.VerifyScratchCode(key, 12345678, hashed)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = totp.VerifyScratchCode(key, 12345679, hashed)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = totp.VerifyScratchCode([]byte("other"), 12345678, hashed)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = totp.HashScratchCode(nil, 12345678)
+	assert.ErrorIs(t, err, totp.ErrMissingScratchKey)
+	_, err = totp.VerifyScratchCode([]byte{}, 12345678, hashed)
+	assert.ErrorIs(t, err, totp.ErrMissingScratchKey)
+
+	for _, v := range []string{"", "abc", "()$abc", "abc$()"} {
+		_, err = totp.VerifyScratchCode(key, 12345678, v)
+		assert.ErrorIs(t, err, totp.ErrInvalidScratchHash)
+	}
+}
+
+func TestHashedScratchCodes(t *testing.T) {
+	x := &totp.Totp{
+		Secret:     "2SH3V3GDW7ZNMGYE",
+		ScratchKey: []byte("8QuyG9RmUwBzF2tC"),
+	}
+	codes, err := x.GenerateHashedScratchCodes(5)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 5)
+	assert.Len(t, x.HashedScratchCodes, 5)
+	assert.Empty(t, x.ScratchCodes)
+	for i, v := range codes {
+		assert.NotContains(t, strings.Join(x.HashedScratchCodes, ","), strconv.Itoa(v))
+		r, err := x.Authenticate(strconv.Itoa(v))
+		assert.NoError(t, err)
+		assert.True(t, r)
+		assert.Len(t, x.HashedScratchCodes, 4-i)
+		r, _ = x.Authenticate(strconv.Itoa(v))
+		assert.False(t, r)
+	}
+}
+
+func TestHashedScratchCodesMissingKey(t *testing.T) {
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE"}
+	_, err := x.GenerateHashedScratchCodes(5)
+	assert.ErrorIs(t, err, totp.ErrMissingScratchKey)
+	assert.Empty(t, x.HashedScratchCodes)
+
+	// A state loaded without its key fails loudly instead of never matching.
+	x.ScratchKey = []byte("8QuyG9RmUwBzF2tC")
+	codes, err := x.GenerateHashedScratchCodes(1)
+	assert.NoError(t, err)
+	x.ScratchKey = nil
+	r, err := x.Authenticate(strconv.Itoa(codes[0]))
+	assert.ErrorIs(t, err, totp.ErrMissingScratchKey)
+	assert.False(t, r)
+	assert.Len(t, x.HashedScratchCodes, 1)
+}
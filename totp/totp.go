@@ -1,51 +1,321 @@
 package totp
 
 import (
-	"crypto/hmac"
+	"context"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base32"
 	"encoding/binary"
 	"errors"
+	"hash"
+	"math/big"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	ErrNotMatch = errors.New("code does not match")
+	ErrNotMatch    = errors.New("code does not match")
+	ErrInvalidSize = errors.New("size must be greater than zero")
+	ErrNotHotp     = errors.New("counter based mode is not enabled")
+)
+
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+func (x Algorithm) Hash() func() hash.Hash {
+	switch x {
+	case "", SHA1:
+		return sha1.New
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	}
+	return nil
+}
+
+const (
+	DefaultDigits     = 6
+	DefaultPeriod     = 30
+	DefaultWindow     = 3
+	DefaultSecretSize = 20
+	DefaultLookAhead  = 100
 )
 
 type Totp struct {
+	Issuer        string
+	Account       string
 	Secret        string
+	Algorithm     Algorithm
+	Digits        int
+	Period        int
 	Window        int
+	Drift         int
+	MaxDrift      int
+	Hotp          bool
 	Counter       int
 	DisallowReuse []int
 	ScratchCodes  []int
+
+	HashedScratchCodes []string
+	ScratchKey         []byte `json:"-"`
+
+	Subject string
+	Version int64
+	Replay  Replay `json:"-"`
+	Clock   Clock  `json:"-"`
+	Guard   *Guard `json:"-"`
+}
+
+func New(options ...Option) (x *Totp, err error) {
+	x = &Totp{
+		Window: DefaultWindow,
+	}
+	for _, v := range options {
+		v(x)
+	}
+	if x.Secret == "" {
+		if x.Secret, err = GenerateSecret(DefaultSecretSize); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+type Option func(x *Totp)
+
+func SetIssuer(v string) Option {
+	return func(x *Totp) {
+		x.Issuer = v
+	}
+}
+
+func SetAccount(v string) Option {
+	return func(x *Totp) {
+		x.Account = v
+	}
+}
+
+func SetSecret(v string) Option {
+	return func(x *Totp) {
+		x.Secret = v
+	}
+}
+
+func SetAlgorithm(v Algorithm) Option {
+	return func(x *Totp) {
+		x.Algorithm = v
+	}
+}
+
+func SetDigits(v int) Option {
+	return func(x *Totp) {
+		x.Digits = v
+	}
+}
+
+func SetPeriod(v int) Option {
+	return func(x *Totp) {
+		x.Period = v
+	}
+}
+
+func SetWindow(v int) Option {
+	return func(x *Totp) {
+		x.Window = v
+	}
+}
+
+func SetMaxDrift(v int) Option {
+	return func(x *Totp) {
+		x.MaxDrift = v
+	}
+}
+
+func SetHotp(counter int) Option {
+	return func(x *Totp) {
+		x.Hotp = true
+		x.Counter = counter
+	}
+}
+
+func GenerateSecret(size int) (string, error) {
+	if size <= 0 {
+		return "", ErrInvalidSize
+	}
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+func GenerateScratchCodes(n int) (codes []int, err error) {
+	if n <= 0 {
+		return nil, ErrInvalidSize
+	}
+	codes = make([]int, 0, n)
+	seen := make(map[int]bool, n)
+	limit := big.NewInt(90000000)
+	for len(codes) < n {
+		var v *big.Int
+		if v, err = rand.Int(rand.Reader, limit); err != nil {
+			return nil, err
+		}
+		// 8 digits with a non-zero leading digit, as accepted by Authenticate.
+		code := int(v.Int64()) + 10000000
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return
+}
+
+func (x *Totp) GetDigits() int {
+	if x.Digits == 0 {
+		return DefaultDigits
+	}
+	return x.Digits
+}
+
+func (x *Totp) GetPeriod() int {
+	if x.Period == 0 {
+		return DefaultPeriod
+	}
+	return x.Period
+}
+
+func (x *Totp) GetDrift() int {
+	if x.MaxDrift <= 0 {
+		return 0
+	}
+	return max(-x.MaxDrift, min(x.MaxDrift, x.Drift))
+}
+
+func (x *Totp) IsHotp() bool {
+	return x.Hotp || x.Counter > 0
+}
+
+func (x *Totp) Compute(value int64) int {
+	key, err := x.Key()
+	if err != nil {
+		return -1
+	}
+	return key.Compute(value)
+}
+
+func (x *Totp) Key() (*Key, error) {
+	return NewKey(x.Secret, x.Algorithm, x.GetDigits())
+}
+
+type Clock interface {
+	Now() time.Time
+}
+
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+func (x *Totp) Now() time.Time {
+	if x.Clock == nil {
+		return time.Now()
+	}
+	return x.Clock.Now()
+}
+
+func (x *Totp) TimeStep(t time.Time) int {
+	period := int64(x.GetPeriod())
+	ts := t.UTC().Unix()
+	if ts < 0 {
+		ts -= period - 1
+	}
+	return int(ts / period)
 }
 
 func (x *Totp) Authenticate(password string) (bool, error) {
-	var scratch bool
-	switch {
-	case len(password) == 6 && password[0] >= '0' && password[0] <= '9':
-		break
-	case len(password) == 8 && password[0] >= '1' && password[0] <= '9':
-		scratch = true
-		break
-	default:
+	return x.authenticate(context.Background(), x.Now(), password)
+}
+
+func (x *Totp) AuthenticateContext(ctx context.Context, password string) (bool, error) {
+	return x.authenticate(ctx, x.Now(), password)
+}
+
+func (x *Totp) AuthenticateAt(t time.Time, password string) (bool, error) {
+	return x.authenticate(context.Background(), t, password)
+}
+
+func (x *Totp) authenticate(ctx context.Context, at time.Time, password string) (ok bool, err error) {
+	if x.Guard == nil {
+		return x.verify(ctx, at, password)
+	}
+	if x.Subject == "" {
+		return false, ErrMissingSubject
+	}
+	if err = x.Guard.Attempt(ctx, x.Subject); err != nil {
+		return false, err
+	}
+	if ok, err = x.verify(ctx, at, password); ok {
+		x.Guard.Reset(ctx, x.Subject)
+	}
+	return
+}
+
+func (x *Totp) verify(ctx context.Context, at time.Time, password string) (bool, error) {
+	otp := len(password) == x.GetDigits() && password[0] >= '0' && password[0] <= '9'
+	scratch := len(password) == 8 && password[0] >= '1' && password[0] <= '9'
+	if !otp && !scratch {
 		return false, ErrNotMatch
 	}
 	code, err := strconv.Atoi(password)
 	if err != nil {
 		return false, ErrNotMatch
 	}
-	if scratch {
-		return x.CheckScratchCodes(code), nil
+	if otp {
+		var ok bool
+		if ok, err = x.checkOtp(ctx, at, code); err != nil {
+			return false, err
+		}
+		// 8-digit codes are ambiguous with scratch codes, so fall through.
+		if ok || !scratch {
+			return ok, nil
+		}
+	}
+	// ScratchKey is not persisted, a state loaded without it can not check hashed codes.
+	if len(x.HashedScratchCodes) != 0 && len(x.ScratchKey) == 0 {
+		return false, ErrMissingScratchKey
 	}
-	if x.Counter > 0 {
+	return x.CheckScratchCodes(code), nil
+}
+
+func (x *Totp) checkOtp(ctx context.Context, at time.Time, code int) (ok bool, err error) {
+	if x.IsHotp() {
 		return x.CheckCode(code), nil
 	}
-	ts := int(time.Now().UTC().Unix() / 30)
-	return x.CheckTotpCode(ts, code), nil
+	ts := x.TimeStep(at)
+	t, ok := x.matchTotpCode(ts, code)
+	if ok && x.Replay != nil {
+		// Only a step the store lets through may update the local state.
+		if ok, err = x.use(ctx, t); err != nil || !ok {
+			return
+		}
+	}
+	if ok {
+		x.acceptTotpCode(ts, t)
+	}
+	return
 }
 
 func (x *Totp) CheckScratchCodes(code int) bool {
@@ -57,64 +327,132 @@ func (x *Totp) CheckScratchCodes(code int) bool {
 			return true
 		}
 	}
-	return false
+	return x.CheckHashedScratchCodes(code)
 }
 
 func (x *Totp) CheckCode(code int) bool {
+	key, err := x.Key()
+	if err != nil {
+		return false
+	}
 	for i := 0; i < x.Window; i++ {
-		if Compute(x.Secret, int64(x.Counter+i)) == code {
+		if key.Compute(int64(x.Counter+i)) == code {
 			x.Counter += i + 1
 			return true
 		}
 	}
-	x.Counter++
 	return false
 }
 
+func (x *Totp) Resync(first string, second string, lookAhead int) (bool, error) {
+	if !x.IsHotp() {
+		return false, ErrNotHotp
+	}
+	digits := x.GetDigits()
+	codes := make([]int, 2)
+	for i, v := range []string{first, second} {
+		if len(v) != digits || v[0] < '0' || v[0] > '9' {
+			return false, ErrNotMatch
+		}
+		var err error
+		if codes[i], err = strconv.Atoi(v); err != nil {
+			return false, ErrNotMatch
+		}
+	}
+	if lookAhead <= 0 {
+		lookAhead = DefaultLookAhead
+	}
+	key, err := x.Key()
+	if err != nil {
+		return false, nil
+	}
+	// RFC 4226 7.4, two consecutive codes re-anchor the counter.
+	next := key.Compute(int64(x.Counter))
+	for i := 0; i < lookAhead; i++ {
+		current := next
+		next = key.Compute(int64(x.Counter + i + 1))
+		if current == codes[0] && next == codes[1] {
+			x.Counter += i + 2
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (x *Totp) CheckTotpCode(ts, code int) bool {
-	minT := ts - (x.Window / 2)
-	maxT := ts + (x.Window / 2)
+	t, ok := x.matchTotpCode(ts, code)
+	if ok {
+		x.acceptTotpCode(ts, t)
+	}
+	return ok
+}
+
+func (x *Totp) matchTotpCode(ts, code int) (int, bool) {
+	key, err := x.Key()
+	if err != nil {
+		return 0, false
+	}
+	drift := x.GetDrift()
+	minT := ts + drift - (x.Window / 2)
+	maxT := ts + drift + (x.Window / 2)
 	for t := minT; t <= maxT; t++ {
-		if Compute(x.Secret, int64(t)) == code {
-			if x.DisallowReuse != nil {
-				for _, timeCode := range x.DisallowReuse {
-					if timeCode == t {
-						return false
-					}
-				}
-				x.DisallowReuse = append(x.DisallowReuse, t)
-				sort.Ints(x.DisallowReuse)
-				m := 0
-				for x.DisallowReuse[m] < minT {
-					m++
+		if key.Compute(int64(t)) == code {
+			for _, timeCode := range x.DisallowReuse {
+				if timeCode == t {
+					return t, false
 				}
-				x.DisallowReuse = x.DisallowReuse[m:]
 			}
-			return true
+			return t, true
 		}
 	}
-	return false
+	return 0, false
 }
 
-func Compute(secret string, value int64) int {
-	key, err := base32.StdEncoding.DecodeString(secret)
-	if err != nil {
-		return -1
+func (x *Totp) acceptTotpCode(ts, t int) {
+	if x.DisallowReuse != nil {
+		// Keep every step drift could still bring back into the window.
+		minT := ts - max(x.MaxDrift, 0) - (x.Window / 2)
+		x.DisallowReuse = append(x.DisallowReuse, t)
+		sort.Ints(x.DisallowReuse)
+		m := 0
+		for x.DisallowReuse[m] < minT {
+			m++
+		}
+		x.DisallowReuse = x.DisallowReuse[m:]
+	}
+	if x.MaxDrift > 0 {
+		x.Drift = max(-x.MaxDrift, min(x.MaxDrift, t-ts))
 	}
+}
+
+func Compute(secret string, value int64) int {
+	return ComputeWith(secret, value, SHA1, DefaultDigits)
+}
 
-	hash := hmac.New(sha1.New, key)
-	err = binary.Write(hash, binary.BigEndian, value)
+func ComputeWith(secret string, value int64, algorithm Algorithm, digits int) int {
+	key, err := NewKey(secret, algorithm, digits)
 	if err != nil {
 		return -1
 	}
-	h := hash.Sum(nil)
+	return key.Compute(value)
+}
 
-	offset := h[19] & 0x0f
+func truncate(sum []byte, digits int) int {
+	offset := sum[len(sum)-1] & 0x0f
 
-	truncated := binary.BigEndian.Uint32(h[offset : offset+4])
+	truncated := binary.BigEndian.Uint32(sum[offset : offset+4])
 
 	truncated &= 0x7fffffff
-	code := truncated % 1000000
+	modulus := int64(1)
+	for i := 0; i < digits; i++ {
+		modulus *= 10
+	}
+	code := int64(truncated) % modulus
 
 	return int(code)
 }
+
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+}
@@ -1,13 +1,30 @@
 package totp_test
 
 import (
+	"encoding/base32"
 	"fmt"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/weplanx/go/totp"
+	"log"
+	"os"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
 
+var rdb *redis.Client
+
+func TestMain(m *testing.M) {
+	opts, err := redis.ParseURL(os.Getenv("DATABASE_REDIS"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rdb = redis.NewClient(opts)
+	os.Exit(m.Run())
+}
+
 type Value1 struct {
 	code   string
 	result bool
@@ -102,3 +119,336 @@ func TestTotpCode(t *testing.T) {
 		assert.True(t, same)
 	}
 }
+
+func TestComputeWith(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	assert.Equal(t, 94287082, totp.ComputeWith(secret, 1, totp.SHA1, 8))
+	assert.Equal(t, 287082, totp.ComputeWith(secret, 1, totp.SHA1, 6))
+	assert.Equal(t, totp.Compute(secret, 1), totp.ComputeWith(secret, 1, "", 6))
+	assert.Equal(t, -1, totp.ComputeWith(secret, 1, "MD5", 6))
+	assert.Equal(t, -1, totp.ComputeWith(secret, 1, totp.SHA1, 11))
+	assert.Equal(t, -1, totp.ComputeWith("?", 1, totp.SHA1, 6))
+
+	secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012"))
+	assert.Equal(t, 46119246, totp.ComputeWith(secret, 1, totp.SHA256, 8))
+	secret = base32.StdEncoding.EncodeToString(
+		[]byte("1234567890123456789012345678901234567890123456789012345678901234"))
+	assert.Equal(t, 90693936, totp.ComputeWith(secret, 1, totp.SHA512, 8))
+}
+
+func TestAuthenticateWithOptions(t *testing.T) {
+	x := &totp.Totp{
+		Secret:       "2SH3V3GDW7ZNMGYE",
+		Algorithm:    totp.SHA256,
+		Digits:       8,
+		Period:       60,
+		Window:       3,
+		ScratchCodes: []int{11112222},
+	}
+	ts := time.Now().UTC().Unix() / 60
+	code := fmt.Sprintf("%08d", totp.ComputeWith(x.Secret, ts, totp.SHA256, 8))
+	r, err := x.Authenticate(code)
+	assert.NoError(t, err)
+	assert.True(t, r)
+	r, _ = x.Authenticate(fmt.Sprintf("%06d", totp.Compute(x.Secret, ts)))
+	assert.False(t, r)
+	r, _ = x.Authenticate("11112222")
+	assert.True(t, r)
+	assert.Empty(t, x.ScratchCodes)
+}
+
+func TestNew(t *testing.T) {
+	x, err := totp.New(
+		totp.SetIssuer("Example"),
+		totp.SetAccount("alice"),
+		totp.SetAlgorithm(totp.SHA256),
+		totp.SetDigits(8),
+		totp.SetPeriod(60),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "Example", x.Issuer)
+	assert.Equal(t, "alice", x.Account)
+	assert.Equal(t, totp.DefaultWindow, x.Window)
+	assert.Len(t, x.Secret, 32)
+	assert.False(t, x.IsHotp())
+	key, err := totp.DecodeSecret(x.Secret)
+	assert.NoError(t, err)
+	assert.Len(t, key, totp.DefaultSecretSize)
+
+	y, err := totp.New(totp.SetSecret("2SH3V3GDW7ZNMGYE"), totp.SetWindow(5), totp.SetHotp(1))
+	assert.NoError(t, err)
+	assert.Equal(t, "2SH3V3GDW7ZNMGYE", y.Secret)
+	assert.Equal(t, 5, y.Window)
+	assert.True(t, y.IsHotp())
+	assert.Equal(t, 1, y.Counter)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	v1, err := totp.GenerateSecret(10)
+	assert.NoError(t, err)
+	assert.Len(t, v1, 16)
+	v2, err := totp.GenerateSecret(10)
+	assert.NoError(t, err)
+	assert.NotEqual(t, v1, v2)
+	v3, err := totp.GenerateSecret(32)
+	assert.NoError(t, err)
+	key, err := totp.DecodeSecret(v3)
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+	_, err = totp.GenerateSecret(0)
+	assert.ErrorIs(t, err, totp.ErrInvalidSize)
+}
+
+func TestGenerateScratchCodes(t *testing.T) {
+	codes, err := totp.GenerateScratchCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	seen := map[int]bool{}
+	for _, v := range codes {
+		assert.GreaterOrEqual(t, v, 10000000)
+		assert.LessOrEqual(t, v, 99999999)
+		assert.False(t, seen[v])
+		seen[v] = true
+	}
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", ScratchCodes: codes}
+	r, err := x.Authenticate(strconv.Itoa(codes[3]))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Len(t, x.ScratchCodes, 9)
+	_, err = totp.GenerateScratchCodes(0)
+	assert.ErrorIs(t, err, totp.ErrInvalidSize)
+}
+
+var rfc6238 = []struct {
+	at        int64
+	algorithm totp.Algorithm
+	code      string
+}{
+	{59, totp.SHA1, "94287082"},
+	{59, totp.SHA256, "46119246"},
+	{59, totp.SHA512, "90693936"},
+	{1111111109, totp.SHA1, "07081804"},
+	{1111111109, totp.SHA256, "68084774"},
+	{1111111109, totp.SHA512, "25091201"},
+	{1111111111, totp.SHA1, "14050471"},
+	{1111111111, totp.SHA256, "67062674"},
+	{1111111111, totp.SHA512, "99943326"},
+	{1234567890, totp.SHA1, "89005924"},
+	{1234567890, totp.SHA256, "91819424"},
+	{1234567890, totp.SHA512, "93441116"},
+	{2000000000, totp.SHA1, "69279037"},
+	{2000000000, totp.SHA256, "90698825"},
+	{2000000000, totp.SHA512, "38618901"},
+	{20000000000, totp.SHA1, "65353130"},
+	{20000000000, totp.SHA256, "77737706"},
+	{20000000000, totp.SHA512, "47863826"},
+}
+
+func TestRFC6238(t *testing.T) {
+	seeds := map[totp.Algorithm]string{
+		totp.SHA1:   "12345678901234567890",
+		totp.SHA256: "12345678901234567890123456789012",
+		totp.SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	for _, v := range rfc6238 {
+		x := &totp.Totp{
+			Secret:    base32.StdEncoding.EncodeToString([]byte(seeds[v.algorithm])),
+			Algorithm: v.algorithm,
+			Digits:    8,
+			Window:    1,
+		}
+		at := time.Unix(v.at, 0)
+		r, err := x.AuthenticateAt(at, v.code)
+		assert.NoError(t, err)
+		assert.True(t, r, v)
+		r, _ = x.AuthenticateAt(at.Add(time.Second*30), v.code)
+		assert.False(t, r, v)
+
+		x.Clock = totp.ClockFunc(func() time.Time { return at })
+		r, err = x.Authenticate(v.code)
+		assert.NoError(t, err)
+		assert.True(t, r, v)
+	}
+}
+
+func TestTimeStep(t *testing.T) {
+	x := &totp.Totp{}
+	assert.Equal(t, 0, x.TimeStep(time.Unix(29, 0)))
+	assert.Equal(t, 1, x.TimeStep(time.Unix(30, 0)))
+	assert.Equal(t, -1, x.TimeStep(time.Unix(-1, 0)))
+	x.Period = 60
+	assert.Equal(t, 0, x.TimeStep(time.Unix(59, 0)))
+	assert.WithinDuration(t, time.Now(), x.Now(), time.Second)
+	x.Clock = totp.ClockFunc(func() time.Time { return time.Unix(120, 0) })
+	assert.Equal(t, 2, x.TimeStep(x.Now()))
+}
+
+func TestCheckCodeFailure(t *testing.T) {
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Counter: 1}
+	for i := 0; i < 10; i++ {
+		assert.False(t, x.CheckCode(totp.Compute(x.Secret, 1)+1))
+	}
+	assert.Equal(t, 1, x.Counter)
+	assert.True(t, x.CheckCode(totp.Compute(x.Secret, 3)))
+	assert.Equal(t, 4, x.Counter)
+}
+
+func TestResync(t *testing.T) {
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 3, Hotp: true}
+	code := func(c int64) string {
+		return fmt.Sprintf("%06d", totp.Compute(x.Secret, c))
+	}
+	// The token has been pressed far beyond the window.
+	r, err := x.Authenticate(code(50))
+	assert.NoError(t, err)
+	assert.False(t, r)
+
+	r, err = x.Resync(code(50), code(52), 0)
+	assert.NoError(t, err)
+	assert.False(t, r)
+	assert.Equal(t, 0, x.Counter)
+
+	r, err = x.Resync(code(50), code(51), 10)
+	assert.NoError(t, err)
+	assert.False(t, r)
+
+	r, err = x.Resync(code(50), code(51), 0)
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, 52, x.Counter)
+
+	r, err = x.Authenticate(code(52))
+	assert.NoError(t, err)
+	assert.True(t, r)
+	assert.Equal(t, 53, x.Counter)
+
+	_, err = x.Resync("abc", code(1), 0)
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	_, err = x.Resync(code(1), "1234567", 0)
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	_, err = (&totp.Totp{Secret: x.Secret}).Resync(code(1), code(2), 0)
+	assert.ErrorIs(t, err, totp.ErrNotHotp)
+}
+
+func TestDrift(t *testing.T) {
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 3, MaxDrift: 4}
+	now := time.Unix(300000, 0)
+	code := func(step int) string {
+		return fmt.Sprintf("%06d", totp.Compute(x.Secret, int64(x.TimeStep(now)+step)))
+	}
+
+	// A phone running two steps behind is outside the default window.
+	r, _ := x.AuthenticateAt(now, code(-2))
+	assert.False(t, r)
+	r, _ = x.AuthenticateAt(now, code(-1))
+	assert.True(t, r)
+	assert.Equal(t, -1, x.Drift)
+	r, _ = x.AuthenticateAt(now, code(-2))
+	assert.True(t, r)
+	assert.Equal(t, -2, x.Drift)
+	r, _ = x.AuthenticateAt(now, code(-3))
+	assert.True(t, r)
+	assert.Equal(t, -3, x.Drift)
+
+	// Drift is clamped, so it cannot be walked further than MaxDrift.
+	for i := -4; i >= -5; i-- {
+		r, _ = x.AuthenticateAt(now, code(i))
+		assert.True(t, r)
+		assert.Equal(t, -4, x.Drift)
+	}
+	r, _ = x.AuthenticateAt(now, code(-6))
+	assert.False(t, r)
+	assert.Equal(t, -4, x.Drift)
+
+	// Drift is ignored unless tracking is enabled.
+	x.MaxDrift = 0
+	assert.Equal(t, 0, x.GetDrift())
+	r, _ = x.AuthenticateAt(now, code(-4))
+	assert.False(t, r)
+	r, _ = x.AuthenticateAt(now, code(0))
+	assert.True(t, r)
+	assert.Equal(t, -4, x.Drift)
+
+	// Moving drift back down must not reopen steps that were already used.
+	x = &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 3, MaxDrift: 2, DisallowReuse: []int{}}
+	r, _ = x.AuthenticateAt(now.Add(-30*time.Second), code(-2))
+	assert.True(t, r)
+	r, _ = x.AuthenticateAt(now, code(0))
+	assert.True(t, r)
+	r, _ = x.AuthenticateAt(now, code(-1))
+	assert.True(t, r)
+	assert.Equal(t, -1, x.Drift)
+	r, _ = x.AuthenticateAt(now, code(-2))
+	assert.False(t, r)
+}
+
+func TestKey(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	key, err := totp.NewKey(secret, totp.SHA1, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, 94287082, key.Compute(1))
+	assert.Equal(t, 7081804, key.Compute(37037036))
+	assert.Equal(t, 94287082, key.Compute(1))
+	assert.Equal(t, 0.0, testing.AllocsPerRun(100, func() {
+		key.Compute(37037036)
+	}))
+
+	_, err = totp.NewKey(secret, "MD5", 6)
+	assert.ErrorIs(t, err, totp.ErrInvalidAlgorithm)
+	_, err = totp.NewKey(secret, totp.SHA1, 0)
+	assert.ErrorIs(t, err, totp.ErrInvalidDigits)
+	_, err = totp.NewKey("1!", totp.SHA1, 6)
+	assert.Error(t, err)
+
+	x := &totp.Totp{Secret: secret}
+	assert.Equal(t, 287082, x.Compute(1))
+	x.Digits = 8
+	assert.Equal(t, 94287082, x.Compute(1))
+	x.Secret = "1!"
+	assert.Equal(t, -1, x.Compute(1))
+	assert.False(t, x.CheckTotpCode(1, 94287082))
+}
+
+func TestConcurrentCheck(t *testing.T) {
+	// Run with -race, a shared read-only Totp must not share HMAC state.
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 5}
+	ts := 10000
+	code := x.Compute(int64(ts))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.True(t, x.CheckTotpCode(ts, code))
+				assert.Equal(t, code, x.Compute(int64(ts)))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCompute(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		totp.Compute("2SH3V3GDW7ZNMGYE", int64(i))
+	}
+}
+
+func BenchmarkKeyCompute(b *testing.B) {
+	key, _ := totp.NewKey("2SH3V3GDW7ZNMGYE", totp.SHA1, 6)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key.Compute(int64(i))
+	}
+}
+
+func BenchmarkCheckTotpCode(b *testing.B) {
+	x := &totp.Totp{Secret: "2SH3V3GDW7ZNMGYE", Window: 21}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x.CheckTotpCode(10000, 1)
+	}
+}
@@ -0,0 +1,166 @@
+package totp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sync"
+)
+
+var (
+	ErrStateNotExists = errors.New("the state does not exists")
+	ErrConflict       = errors.New("the state has been modified concurrently")
+)
+
+type Repository interface {
+	Load(ctx context.Context, subject string) (*Totp, error)
+	Save(ctx context.Context, subject string, data *Totp) error
+}
+
+type Verifier struct {
+	Repository Repository
+	Retries    int
+	Prepare    func(data *Totp)
+}
+
+func NewVerifier(repository Repository) *Verifier {
+	return &Verifier{Repository: repository, Retries: 3}
+}
+
+func (x *Verifier) Verify(ctx context.Context, subject string, password string) (ok bool, err error) {
+	for i := 0; i <= x.Retries; i++ {
+		var data *Totp
+		if data, err = x.Repository.Load(ctx, subject); err != nil {
+			return false, err
+		}
+		if x.Prepare != nil {
+			x.Prepare(data)
+		}
+		if i > 0 {
+			// The first attempt already passed the guard and claimed the replay step,
+			// a retry only re-applies the same password to the newer state.
+			data.Replay = nil
+			data.Guard = nil
+		}
+		// Failed attempts leave the state untouched, there is nothing to save.
+		if ok, err = data.AuthenticateContext(ctx, password); err != nil || !ok {
+			return false, err
+		}
+		if err = x.Repository.Save(ctx, subject, data); err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return false, err
+		}
+	}
+	return false, ErrConflict
+}
+
+type MemoryRepository struct {
+	mu    sync.Mutex
+	items map[string][]byte
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{items: make(map[string][]byte)}
+}
+
+func (x *MemoryRepository) Load(_ context.Context, subject string) (data *Totp, err error) {
+	x.mu.Lock()
+	b, ok := x.items[subject]
+	x.mu.Unlock()
+	if !ok {
+		return nil, ErrStateNotExists
+	}
+	data = new(Totp)
+	if err = json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (x *MemoryRepository) Save(_ context.Context, subject string, data *Totp) (err error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var current Totp
+	if b, ok := x.items[subject]; ok {
+		if err = json.Unmarshal(b, &current); err != nil {
+			return
+		}
+	}
+	if current.Version != data.Version {
+		return ErrConflict
+	}
+	data.Version++
+	var b []byte
+	if b, err = json.Marshal(data); err != nil {
+		data.Version--
+		return
+	}
+	x.items[subject] = b
+	return
+}
+
+type RedisRepository struct {
+	RDb *redis.Client
+}
+
+func NewRedisRepository(rdb *redis.Client) *RedisRepository {
+	return &RedisRepository{RDb: rdb}
+}
+
+func (x *RedisRepository) Key(subject string) string {
+	return fmt.Sprintf(`totp:state:%s`, subject)
+}
+
+func (x *RedisRepository) Load(ctx context.Context, subject string) (data *Totp, err error) {
+	var b []byte
+	if b, err = x.RDb.HGet(ctx, x.Key(subject), "data").Bytes(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, ErrStateNotExists
+		}
+		return
+	}
+	data = new(Totp)
+	if err = json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	return
+}
+
+var compareAndSwap = redis.NewScript(`
+local current = tonumber(redis.call('HGET', KEYS[1], 'version') or '0')
+if current ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[2], 'data', ARGV[3])
+return 1
+`)
+
+func (x *RedisRepository) Save(ctx context.Context, subject string, data *Totp) (err error) {
+	version := data.Version
+	data.Version++
+	defer func() {
+		if err != nil {
+			data.Version = version
+		}
+	}()
+	var b []byte
+	if b, err = json.Marshal(data); err != nil {
+		return
+	}
+	var n int64
+	if n, err = compareAndSwap.Run(ctx, x.RDb, []string{x.Key(subject)}, version, data.Version, b).Int64(); err != nil {
+		return
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return
+}
+
+func (x *RedisRepository) Delete(ctx context.Context, subject string) int64 {
+	return x.RDb.Del(ctx, x.Key(subject)).Val()
+}
@@ -0,0 +1,169 @@
+package totp_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testRepository(t *testing.T, repository totp.Repository) {
+	ctx := context.TODO()
+	_, err := repository.Load(ctx, "verifier")
+	assert.ErrorIs(t, err, totp.ErrStateNotExists)
+
+	codes, err := totp.GenerateScratchCodes(3)
+	assert.NoError(t, err)
+	data := &totp.Totp{
+		Secret:        "2SH3V3GDW7ZNMGYE",
+		Window:        3,
+		DisallowReuse: []int{},
+		ScratchCodes:  codes,
+	}
+	assert.NoError(t, repository.Save(ctx, "verifier", data))
+	assert.Equal(t, int64(1), data.Version)
+
+	stale := *data
+	stale.Version = 0
+	assert.ErrorIs(t, repository.Save(ctx, "verifier", &stale), totp.ErrConflict)
+	assert.Equal(t, int64(0), stale.Version)
+
+	x := totp.NewVerifier(repository)
+	x.Retries = 10
+
+	// Concurrent logins with the same scratch code, only one may win.
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := x.Verify(ctx, "verifier", strconv.Itoa(codes[0]))
+			if err != nil {
+				assert.ErrorIs(t, err, totp.ErrConflict)
+			}
+			if ok {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), accepted.Load())
+
+	ok, err := x.Verify(ctx, "verifier", "000000")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	prepared := false
+	x.Prepare = func(data *totp.Totp) {
+		prepared = true
+	}
+	ok, err = x.Verify(ctx, "verifier", strconv.Itoa(codes[1]))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, prepared)
+
+	data, err = repository.Load(ctx, "verifier")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{codes[2]}, data.ScratchCodes)
+	assert.Equal(t, int64(3), data.Version)
+
+	_, err = x.Verify(ctx, "unknown", "000000")
+	assert.ErrorIs(t, err, totp.ErrStateNotExists)
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, totp.NewMemoryRepository())
+}
+
+func TestRedisRepository(t *testing.T) {
+	repository := totp.NewRedisRepository(rdb)
+	repository.Delete(context.TODO(), "verifier")
+	testRepository(t, repository)
+	assert.Equal(t, int64(1), repository.Delete(context.TODO(), "verifier"))
+}
+
+type conflictRepository struct {
+	*totp.MemoryRepository
+	conflicts int
+}
+
+func (x *conflictRepository) Save(ctx context.Context, subject string, data *totp.Totp) error {
+	if x.conflicts > 0 {
+		x.conflicts--
+		return totp.ErrConflict
+	}
+	return x.MemoryRepository.Save(ctx, subject, data)
+}
+
+type memoryReplay struct {
+	used map[int64]bool
+	ttl  time.Duration
+}
+
+func (x *memoryReplay) Use(_ context.Context, _ string, step int64, ttl time.Duration) (bool, error) {
+	x.ttl = ttl
+	if x.used[step] {
+		return false, nil
+	}
+	x.used[step] = true
+	return true, nil
+}
+
+type memoryLimiter struct {
+	mu       sync.Mutex
+	attempts int64
+}
+
+func (x *memoryLimiter) Update(_ context.Context, _ string, _ time.Duration) int64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.attempts++
+	return x.attempts
+}
+
+func (x *memoryLimiter) Delete(_ context.Context, _ string) int64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.attempts = 0
+	return 1
+}
+
+func TestVerifyReplayConflict(t *testing.T) {
+	ctx := context.TODO()
+	repository := &conflictRepository{MemoryRepository: totp.NewMemoryRepository(), conflicts: 1}
+	assert.NoError(t, repository.MemoryRepository.Save(ctx, "conflict", &totp.Totp{
+		Secret: "2SH3V3GDW7ZNMGYE",
+		Window: 3,
+	}))
+
+	now := time.Unix(300000, 0)
+	replay := &memoryReplay{used: make(map[int64]bool)}
+	limiter := &memoryLimiter{}
+	x := totp.NewVerifier(repository)
+	x.Prepare = func(data *totp.Totp) {
+		data.Subject = "conflict"
+		data.Clock = totp.ClockFunc(func() time.Time { return now })
+		data.Replay = replay
+		data.Guard = totp.NewGuard(limiter, 3, time.Minute)
+	}
+
+	// The retry after a conflict must not trip over its own replay step.
+	code := fmt.Sprintf("%06d", totp.Compute("2SH3V3GDW7ZNMGYE", 10000))
+	ok, err := x.Verify(ctx, "conflict", code)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0, repository.conflicts)
+	assert.Equal(t, int64(0), limiter.attempts)
+
+	// Yet the code stays single use.
+	ok, err = x.Verify(ctx, "conflict", code)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), limiter.attempts)
+}
@@ -0,0 +1,148 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidModhex    = errors.New("invalid modhex string")
+	ErrInvalidYubicoKey = errors.New("invalid yubico otp key")
+	ErrReplayedOtp      = errors.New("otp counter has already been used")
+)
+
+const modhex = "cbdefghijklnrtuv"
+
+func ModhexEncode(b []byte) string {
+	var s strings.Builder
+	s.Grow(len(b) * 2)
+	for _, v := range b {
+		s.WriteByte(modhex[v>>4])
+		s.WriteByte(modhex[v&0x0f])
+	}
+	return s.String()
+}
+
+func ModhexDecode(s string) (_ []byte, err error) {
+	if len(s)%2 == 1 {
+		return nil, ErrInvalidModhex
+	}
+	s = strings.ToLower(s)
+	b := make([]byte, len(s)/2)
+	for i := 0; i < len(s); i++ {
+		n := strings.IndexByte(modhex, s[i])
+		if n < 0 {
+			return nil, ErrInvalidModhex
+		}
+		b[i/2] = b[i/2]<<4 | byte(n)
+	}
+	return b, nil
+}
+
+type YubicoToken struct {
+	Uid            []byte
+	UseCounter     int
+	Timestamp      int
+	SessionCounter int
+	Random         uint16
+	Crc            uint16
+}
+
+func crc16(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, v := range b {
+		crc ^= uint16(v)
+		for i := 0; i < 8; i++ {
+			j := crc & 1
+			crc >>= 1
+			if j != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
+
+type Yubico struct {
+	PublicId       string
+	PrivateId      string
+	AesKey         string
+	UseCounter     int
+	SessionCounter int
+}
+
+func NewYubico(publicId string, privateId string, aesKey string) (x *Yubico, err error) {
+	x = &Yubico{
+		PublicId:  strings.ToLower(publicId),
+		PrivateId: strings.ToLower(privateId),
+		AesKey:    strings.ToLower(aesKey),
+	}
+	if _, err = ModhexDecode(x.PublicId); err != nil || len(x.PublicId) > 32 {
+		return nil, ErrInvalidYubicoKey
+	}
+	if b, err := hex.DecodeString(x.PrivateId); err != nil || len(b) != 6 {
+		return nil, ErrInvalidYubicoKey
+	}
+	if b, err := hex.DecodeString(x.AesKey); err != nil || len(b) != 16 {
+		return nil, ErrInvalidYubicoKey
+	}
+	return
+}
+
+func (x *Yubico) Decode(otp string) (_ *YubicoToken, err error) {
+	otp = strings.ToLower(otp)
+	if len(otp) < 32 || len(otp) > 64 || otp[:len(otp)-32] != x.PublicId {
+		return nil, ErrNotMatch
+	}
+	var b []byte
+	if b, err = ModhexDecode(otp[len(otp)-32:]); err != nil {
+		return nil, ErrNotMatch
+	}
+	var key []byte
+	if key, err = hex.DecodeString(x.AesKey); err != nil {
+		return nil, ErrInvalidYubicoKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidYubicoKey
+	}
+	block.Decrypt(b, b)
+	// The residue of a crc over data followed by its complement.
+	if crc16(b) != 0xf0b8 {
+		return nil, ErrNotMatch
+	}
+	return &YubicoToken{
+		Uid:            b[0:6],
+		UseCounter:     int(binary.LittleEndian.Uint16(b[6:8]) & 0x7fff),
+		Timestamp:      int(b[8]) | int(b[9])<<8 | int(b[10])<<16,
+		SessionCounter: int(b[11]),
+		Random:         binary.LittleEndian.Uint16(b[12:14]),
+		Crc:            binary.LittleEndian.Uint16(b[14:16]),
+	}, nil
+}
+
+func (x *Yubico) Verify(otp string) (_ bool, err error) {
+	var token *YubicoToken
+	if token, err = x.Decode(otp); err != nil {
+		return
+	}
+	var uid []byte
+	if uid, err = hex.DecodeString(x.PrivateId); err != nil {
+		return false, ErrInvalidYubicoKey
+	}
+	if subtle.ConstantTimeCompare(uid, token.Uid) != 1 {
+		return false, nil
+	}
+	// Counters only move forward, an older pair means a replay or a cloned key.
+	if token.UseCounter < x.UseCounter ||
+		(token.UseCounter == x.UseCounter && token.SessionCounter <= x.SessionCounter) {
+		return false, ErrReplayedOtp
+	}
+	x.UseCounter = token.UseCounter
+	x.SessionCounter = token.SessionCounter
+	return true, nil
+}
@@ -0,0 +1,141 @@
+package totp_test
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/weplanx/go/totp"
+	"testing"
+)
+
+const (
+	yubicoPublicId  = "vvccccfiluij"
+	yubicoPrivateId = "8792ebfe26cc"
+	yubicoAesKey    = "ecde18dbe76fbd0c33330f1c354871db"
+)
+
+func yubicoCrc(b []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, v := range b {
+		crc ^= uint16(v)
+		for i := 0; i < 8; i++ {
+			j := crc & 1
+			crc >>= 1
+			if j != 0 {
+				crc ^= 0x8408
+			}
+		}
+	}
+	return crc
+}
+
+func yubicoOtp(t *testing.T, uid string, key string, use uint16, session byte) string {
+	b := make([]byte, 16)
+	id, err := hex.DecodeString(uid)
+	assert.NoError(t, err)
+	copy(b, id)
+	binary.LittleEndian.PutUint16(b[6:], use)
+	b[8], b[9], b[10] = 0x12, 0x34, 0x56
+	b[11] = session
+	binary.LittleEndian.PutUint16(b[12:], 0xbeef)
+	binary.LittleEndian.PutUint16(b[14:], ^yubicoCrc(b[:14]))
+	k, err := hex.DecodeString(key)
+	assert.NoError(t, err)
+	block, err := aes.NewCipher(k)
+	assert.NoError(t, err)
+	block.Encrypt(b, b)
+	return yubicoPublicId + totp.ModhexEncode(b)
+}
+
+func TestModhex(t *testing.T) {
+	assert.Equal(t, "cbdefghijklnrtuv", totp.ModhexEncode([]byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}))
+	b, err := totp.ModhexDecode("CBDEFGHIJKLNRTUV")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}, b)
+	_, err = totp.ModhexDecode("cbd")
+	assert.ErrorIs(t, err, totp.ErrInvalidModhex)
+	_, err = totp.ModhexDecode("cbda")
+	assert.ErrorIs(t, err, totp.ErrInvalidModhex)
+}
+
+func TestNewYubico(t *testing.T) {
+	_, err := totp.NewYubico(yubicoPublicId, yubicoPrivateId, yubicoAesKey)
+	assert.NoError(t, err)
+	_, err = totp.NewYubico("abc", yubicoPrivateId, yubicoAesKey)
+	assert.ErrorIs(t, err, totp.ErrInvalidYubicoKey)
+	_, err = totp.NewYubico(yubicoPublicId, "8792eb", yubicoAesKey)
+	assert.ErrorIs(t, err, totp.ErrInvalidYubicoKey)
+	_, err = totp.NewYubico(yubicoPublicId, yubicoPrivateId, "ecde18")
+	assert.ErrorIs(t, err, totp.ErrInvalidYubicoKey)
+}
+
+func TestYubicoDecode(t *testing.T) {
+	x, err := totp.NewYubico(yubicoPublicId, yubicoPrivateId, yubicoAesKey)
+	assert.NoError(t, err)
+	token, err := x.Decode(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 19, 17))
+	assert.NoError(t, err)
+	assert.Equal(t, yubicoPrivateId, hex.EncodeToString(token.Uid))
+	assert.Equal(t, 19, token.UseCounter)
+	assert.Equal(t, 0x563412, token.Timestamp)
+	assert.Equal(t, 17, token.SessionCounter)
+	assert.Equal(t, uint16(0xbeef), token.Random)
+
+	_, err = x.Decode("cccccccccccc" + totp.ModhexEncode(make([]byte, 16)))
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	_, err = x.Decode(yubicoPublicId + totp.ModhexEncode(make([]byte, 16)))
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+	_, err = x.Decode(yubicoOtp(t, yubicoPrivateId, "00112233445566778899aabbccddeeff", 19, 17))
+	assert.ErrorIs(t, err, totp.ErrNotMatch)
+}
+
+func TestYubicoVector(t *testing.T) {
+	// Published with libyubikey's ykparse, independent of the helpers above.
+	x, err := totp.NewYubico("dteffuje", yubicoPrivateId, yubicoAesKey)
+	assert.NoError(t, err)
+	token, err := x.Decode("dteffujehknhfjbrjnlnldnhcujvddbikngjrtgh")
+	assert.NoError(t, err)
+	assert.Equal(t, yubicoPrivateId, hex.EncodeToString(token.Uid))
+	assert.Equal(t, 19, token.UseCounter)
+	assert.Equal(t, 0xc230, token.Timestamp)
+	assert.Equal(t, 17, token.SessionCounter)
+	assert.Equal(t, uint16(0x9fc8), token.Random)
+	assert.Equal(t, uint16(0xc823), token.Crc)
+
+	ok, err := x.Verify("DTEFFUJEHKNHFJBRJNLNLDNHCUJVDDBIKNGJRTGH")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 19, x.UseCounter)
+	assert.Equal(t, 17, x.SessionCounter)
+}
+
+func TestYubicoVerify(t *testing.T) {
+	x, err := totp.NewYubico(yubicoPublicId, yubicoPrivateId, yubicoAesKey)
+	assert.NoError(t, err)
+
+	ok, err := x.Verify(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 1, 0))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, x.UseCounter)
+	assert.Equal(t, 0, x.SessionCounter)
+
+	ok, err = x.Verify(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 1, 1))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = x.Verify(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 1, 1))
+	assert.ErrorIs(t, err, totp.ErrReplayedOtp)
+	_, err = x.Verify(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 0, 5))
+	assert.ErrorIs(t, err, totp.ErrReplayedOtp)
+
+	// A power cycle resets the session counter and bumps the usage counter.
+	ok, err = x.Verify(yubicoOtp(t, yubicoPrivateId, yubicoAesKey, 2, 0))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, x.UseCounter)
+
+	ok, err = x.Verify(yubicoOtp(t, "000000000000", yubicoAesKey, 3, 0))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, x.UseCounter)
+}